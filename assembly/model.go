@@ -0,0 +1,348 @@
+// Package assembly loads an Onshape assembly definition into a tree of part
+// and sub-assembly occurrences.
+package assembly
+
+import (
+	"errors"
+	"fmt"
+
+	onshapeapi "github.com/onshape-public/go-client/onshape"
+	"onshape-mcjf-exporter/onshape"
+)
+
+type Color struct {
+	R uint8
+	G uint8
+	B uint8
+	A uint8
+}
+
+type PartInfo struct {
+	Id         string
+	Name       string
+	Path onshape.ElementPath
+	Appearance Color
+	// Display name of the Onshape material, e.g. "Aluminum - 6061"
+	Material string
+	// From the Onshape standard content library, e.g. a screw
+	StandardContent bool
+	// Only loaded for standard content merged by mass
+	Mass *MassProperties
+}
+
+// Mass in kilograms, volume in cubic meters and centroid in meters in the
+// part frame. HasMass is false for parts without a material.
+type MassProperties struct {
+	HasMass  bool
+	Mass     float64
+	Volume   float64
+	Centroid [3]float64
+}
+
+type AssemblyInfo struct {
+	Name       string
+	Path onshape.ElementPath
+}
+
+type Occurrence interface {
+	GetTransform() Transform
+	GetId() string
+	GetPath() []string
+	GetName() string
+	IsHidden() bool
+}
+
+type BaseOccurrence struct {
+	Transform Transform
+	Id string
+	// Instance ids from the root down to Id, unique to the occurrence when a
+	// part or sub-assembly is instanced several times
+	Path []string
+	// Instance name shown in the Onshape assembly tree
+	Name string
+	// Hidden in Onshape, itself or through a parent sub-assembly
+	Hidden bool
+}
+
+func (b BaseOccurrence) GetTransform() Transform {
+	return b.Transform
+}
+
+func (b BaseOccurrence) GetId() string {
+	return b.Id
+}
+
+func (b BaseOccurrence) GetPath() []string {
+	return b.Path
+}
+
+func (b BaseOccurrence) GetName() string {
+	return b.Name
+}
+
+func (b BaseOccurrence) IsHidden() bool {
+	return b.Hidden
+}
+
+type AssemblyOccurrence struct {
+	BaseOccurrence
+	Assembly *AssemblyInfo
+	Children []Occurrence
+}
+
+type PartOccurrence struct {
+	BaseOccurrence
+	Part *PartInfo
+}
+
+type ModelData struct {
+	DocumentInfo *onshapeapi.BTDocumentInfo
+	AssemblyDef  *onshapeapi.BTAssemblyDefinitionInfo
+	PartInfoList []PartInfo
+	AssemblyInfoList []AssemblyInfo
+	Occurrences  []Occurrence
+	LoadOptions  LoadOptions
+}
+
+type ElementKey struct {
+	did string
+	wvmid string
+	eid string
+	configuration string
+}
+
+var ErrMissingPart = errors.New("part missing from assembly definition")
+
+// Instance that references a part or sub-assembly absent from the definition
+type MissingPartError struct {
+	Path   onshape.ElementPath
+	PartId string
+}
+
+func (e *MissingPartError) Error() string {
+	if e.PartId == "" {
+		return fmt.Sprintf("sub-assembly %s/%s/%s missing from assembly definition", e.Path.DocumentId, e.Path.WVMId, e.Path.ElementId)
+	}
+	return fmt.Sprintf("part %s in %s/%s/%s missing from assembly definition", e.PartId, e.Path.DocumentId, e.Path.WVMId, e.Path.ElementId)
+}
+
+func (e *MissingPartError) Is(target error) bool {
+	return target == ErrMissingPart
+}
+
+var ErrMissingId = errors.New("id missing from assembly definition")
+
+// Part or sub-assembly of the definition without one of the ids needed to
+// load it
+type MissingIdError struct {
+	// Name of the missing field, e.g. "documentMicroversion"
+	Field string
+	// Whatever identifies the entry, e.g. its part id, empty if unknown
+	Entry string
+}
+
+func (e *MissingIdError) Error() string {
+	if e.Entry == "" {
+		return fmt.Sprintf("%s missing from assembly definition", e.Field)
+	}
+	return fmt.Sprintf("%s of %s missing from assembly definition", e.Field, e.Entry)
+}
+
+func (e *MissingIdError) Is(target error) bool {
+	return target == ErrMissingId
+}
+
+// Path of a part or sub-assembly of the definition, failing with a
+// MissingIdError when an id is absent
+func definitionPath(did string, microversion string, eid string, configuration string, entry string) (onshape.ElementPath, error) {
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"documentId", did},
+		{"documentMicroversion", microversion},
+		{"elementId", eid},
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			return onshape.ElementPath{}, &MissingIdError{Field: field.name, Entry: entry}
+		}
+	}
+	return elementPath(did, microversion, eid, configuration), nil
+}
+
+// Color and opacity of the part metadata. Parts without an appearance get
+// the zero Color, drawn with the default material.
+func partAppearance(info *onshapeapi.BTPartMetadataInfo) Color {
+	appearance := info.GetAppearance()
+	if !appearance.HasColor() {
+		return Color{}
+	}
+	color := appearance.GetColor()
+	opacity := int32(255)
+	if appearance.HasOpacity() {
+		opacity = appearance.GetOpacity()
+	}
+	return Color{
+		R: uint8(color.GetRed()),
+		G: uint8(color.GetGreen()),
+		B: uint8(color.GetBlue()),
+		A: uint8(opacity),
+	}
+}
+
+// Document/WVM/Element => Part Info List
+type ElementPathToPartList map[ElementKey][]onshapeapi.BTPartMetadataInfo
+
+// Returns pointer to added child
+func (a *AssemblyOccurrence) AddChild(child Occurrence) {
+	a.Children = append(a.Children, child)
+}
+
+func (a *AssemblyOccurrence) GetChild(id string) Occurrence {
+	for _, child := range a.Children {
+		if child.GetId() == id {
+			return child
+		}
+	}
+
+	return nil
+}
+
+func getPartInfoList(c *onshape.Client, assemblyDef *onshapeapi.BTAssemblyDefinitionInfo, options LoadOptions) ([]PartInfo, error) {
+	var partInfoList []PartInfo = make([]PartInfo, 0)
+	
+	elementPathToPartList := make(ElementPathToPartList)
+
+	for _, part := range assemblyDef.Parts {
+		standard := part.GetIsStandardContent()
+		if standard && options.skipStandardContent() {
+			continue
+		}
+
+		if !part.HasPartId() {
+			return nil, &MissingIdError{Field: "partId"}
+		}
+		partId := part.GetPartId()
+		path, err := definitionPath(part.GetDocumentId(), part.GetDocumentMicroversion(), part.GetElementId(), part.GetConfiguration(), "part " + partId)
+		if err != nil {
+			return nil, err
+		}
+
+		key := ElementKey{
+			did: path.DocumentId,
+			wvmid: path.WVMId,
+			eid: path.ElementId,
+			configuration: path.Configuration,
+		}
+
+		if elementPathToPartList[key] == nil {
+			partsInfo, err := c.GetPartsInfo(path)
+			if err != nil {
+				return nil, err
+			}
+			elementPathToPartList[key] = partsInfo
+		}
+
+		partInfos := elementPathToPartList[key]
+
+		var name string
+		var material string
+		var appearance Color
+		for i := range partInfos {
+			partInfo := &partInfos[i]
+			if partInfo.GetPartId() != partId {
+				continue
+			}
+
+			name = partInfo.GetName()
+			material = partInfo.Material.GetDisplayName()
+			appearance = partAppearance(partInfo)
+			break
+		}
+
+		var mass *MassProperties
+		if standard && options.StandardContent == STANDARD_CONTENT_MERGE_MASS {
+			properties, err := c.GetMassProperties(path, partId)
+			if err != nil {
+				return nil, err
+			}
+			mass = &MassProperties{HasMass: properties.GetHasMass_()}
+			if len(properties.Mass) > 0 {
+				mass.Mass = properties.Mass[0]
+			}
+			if len(properties.Volume) > 0 {
+				mass.Volume = properties.Volume[0]
+			}
+			copy(mass.Centroid[:], properties.Centroid)
+		}
+
+		partInfoList = append(partInfoList, PartInfo{
+			Id: partId,
+			Name: name,
+			Path: path,
+			Appearance: appearance,
+			Material: material,
+			StandardContent: standard,
+			Mass: mass,
+		})
+	}
+
+	return partInfoList, nil
+}
+
+func getAssemblyInfoList(c *onshape.Client, assemblyDef *onshapeapi.BTAssemblyDefinitionInfo) ([]AssemblyInfo, error) {
+	assemblyInfoList := make([]AssemblyInfo, 0)
+
+	for _, assembly := range assemblyDef.SubAssemblies {
+		path, err := definitionPath(assembly.GetDocumentId(), assembly.GetDocumentMicroversion(), assembly.GetElementId(), assembly.GetConfiguration(), "sub-assembly " + assembly.GetElementId())
+		if err != nil {
+			return nil, err
+		}
+		elementInfo, err := c.GetElementInfo(path)
+		if err != nil {
+			return nil, err
+		}
+		assemblyInfoList = append(assemblyInfoList, AssemblyInfo{
+			Name: elementInfo.GetName(),
+			Path: path,
+		})
+	}
+
+	return assemblyInfoList, nil
+}
+
+func NewModelData(c *onshape.Client, root onshape.ElementPath, options LoadOptions) (ModelData, error) {
+	assemblyDef, err := c.GetAssemblyDefinitionInfo(root)
+	if err != nil {
+		return ModelData{}, err
+	}
+	
+	partInfoList, err := getPartInfoList(c, assemblyDef, options)
+	if err != nil {
+		return ModelData{}, err
+	}
+	assemblyInfoList, err := getAssemblyInfoList(c, assemblyDef)
+	if err != nil {
+		return ModelData{}, err
+	}
+
+	occurrenceList, err := BuildOccurrences(assemblyDef, partInfoList, assemblyInfoList, options)
+	if err != nil {
+		return ModelData{}, err
+	}
+
+	documentInfo, err := c.GetDocumentInfo(root.DocumentId)
+	if err != nil {
+		return ModelData{}, err
+	}
+
+	return ModelData{
+		DocumentInfo: documentInfo,
+		AssemblyDef: assemblyDef,
+		PartInfoList: partInfoList,
+		AssemblyInfoList: assemblyInfoList,
+		Occurrences: occurrenceList,
+		LoadOptions: options,
+	}, nil
+}
@@ -0,0 +1,98 @@
+package assembly
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	onshapeapi "github.com/onshape-public/go-client/onshape"
+	"onshape-mcjf-exporter/onshape"
+	"onshape-mcjf-exporter/onshapetest"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// Loads a definition with one instance of part JHD, described by metadata
+func loadPart(t *testing.T, part onshapeapi.BTAssemblyPartInfo, metadata onshapeapi.BTPartMetadataInfo) (ModelData, error) {
+	t.Helper()
+	s := onshapetest.NewServer()
+	t.Cleanup(s.Close)
+
+	did, mv, ps := "d1", onshapetest.SYNTHETIC_MICROVERSION, onshapetest.SYNTHETIC_PART_STUDIO
+	def := onshapeapi.BTAssemblyDefinitionInfo{
+		Parts: []onshapeapi.BTAssemblyPartInfo{part},
+		RootAssembly: &onshapeapi.BTRootAssemblyInfo{
+			Instances:   []onshapeapi.BTAssemblyInstanceInfo{{Id: ptr("i1"), Name: ptr("Plate <1>"), Type: ptr(onshapeapi.BTAssemblyInstanceTypePart), DocumentId: &did, DocumentMicroversion: &mv, ElementId: &ps, PartId: ptr("JHD")}},
+			Occurrences: []onshapeapi.BTAssemblyOccurrenceInfo{{Path: []string{"i1"}, Transform: translationArray(0, 0, 0)}},
+		},
+	}
+	s.AddDocument(did, "Plates")
+	s.AddAssembly(did, "m", mv, "top", def)
+	s.AddParts(did, "m", mv, ps, []onshapeapi.BTPartMetadataInfo{metadata})
+
+	client := onshape.NewClient(context.Background(), onshape.ClientOptions{ServerURL: s.URL + "/", CacheDir: t.TempDir()})
+	return NewModelData(client, onshape.ElementPath{DocumentId: did, WVM: "m", WVMId: mv, ElementId: "top"}, DefaultLoadOptions())
+}
+
+func translationArray(x float64, y float64, z float64) []float64 {
+	return []float64{
+		1, 0, 0, x,
+		0, 1, 0, y,
+		0, 0, 1, z,
+		0, 0, 0, 1,
+	}
+}
+
+func TestPartsWithoutAppearanceGetTheDefaultColor(t *testing.T) {
+	did, mv, ps := "d1", onshapetest.SYNTHETIC_MICROVERSION, onshapetest.SYNTHETIC_PART_STUDIO
+	part := onshapeapi.BTAssemblyPartInfo{DocumentId: &did, DocumentMicroversion: &mv, ElementId: &ps, PartId: ptr("JHD")}
+	red := &onshapeapi.BTColorInfo{Red: ptr(int32(255)), Green: ptr(int32(0)), Blue: ptr(int32(0))}
+	tests := []struct {
+		name       string
+		appearance *onshapeapi.BTPartAppearanceInfo
+		want       Color
+	}{
+		{"no appearance", nil, Color{}},
+		{"no color", &onshapeapi.BTPartAppearanceInfo{Opacity: ptr(int32(128))}, Color{}},
+		{"no opacity", &onshapeapi.BTPartAppearanceInfo{Color: red}, Color{R: 255, A: 255}},
+		{"color and opacity", &onshapeapi.BTPartAppearanceInfo{Color: red, Opacity: ptr(int32(128))}, Color{R: 255, A: 128}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			model, err := loadPart(t, part, onshapeapi.BTPartMetadataInfo{PartId: ptr("JHD"), Name: ptr("Plate"), Appearance: test.appearance})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(model.PartInfoList) != 1 {
+				t.Fatalf("%d parts, want 1", len(model.PartInfoList))
+			}
+			if got := model.PartInfoList[0]; got.Name != "Plate" || got.Appearance != test.want {
+				t.Errorf("part %s colored %v, want Plate colored %v", got.Name, got.Appearance, test.want)
+			}
+		})
+	}
+}
+
+func TestPartsWithoutIdsFailWithMissingIdError(t *testing.T) {
+	did, mv, ps := "d1", onshapetest.SYNTHETIC_MICROVERSION, onshapetest.SYNTHETIC_PART_STUDIO
+	tests := []struct {
+		field string
+		part  onshapeapi.BTAssemblyPartInfo
+	}{
+		{"partId", onshapeapi.BTAssemblyPartInfo{DocumentId: &did, DocumentMicroversion: &mv, ElementId: &ps}},
+		{"documentId", onshapeapi.BTAssemblyPartInfo{DocumentMicroversion: &mv, ElementId: &ps, PartId: ptr("JHD")}},
+		{"documentMicroversion", onshapeapi.BTAssemblyPartInfo{DocumentId: &did, ElementId: &ps, PartId: ptr("JHD")}},
+		{"elementId", onshapeapi.BTAssemblyPartInfo{DocumentId: &did, DocumentMicroversion: &mv, PartId: ptr("JHD")}},
+	}
+	for _, test := range tests {
+		t.Run(test.field, func(t *testing.T) {
+			_, err := loadPart(t, test.part, onshapeapi.BTPartMetadataInfo{PartId: ptr("JHD"), Name: ptr("Plate")})
+			var missing *MissingIdError
+			if !errors.Is(err, ErrMissingId) || !errors.As(err, &missing) || missing.Field != test.field {
+				t.Errorf("got %v, want a MissingIdError for %s", err, test.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package assembly
+
+// Policies for standard content such as fasteners from the Onshape library
+const (
+	// Leave standard content out of the model
+	STANDARD_CONTENT_SKIP = "skip"
+	// Keep it as geometry that neither collides nor has mass
+	STANDARD_CONTENT_VISUAL = "visual"
+	// Keep only its mass, as a point at its centroid fixed to the parent body
+	STANDARD_CONTENT_MERGE_MASS = "merge_mass"
+)
+
+var StandardContentPolicies = []string{STANDARD_CONTENT_SKIP, STANDARD_CONTENT_VISUAL, STANDARD_CONTENT_MERGE_MASS}
+
+// Policies for instances hidden in Onshape, e.g. a cover hidden to show what
+// is inside. Suppressed instances are always left out.
+const (
+	// Leave hidden instances out, and their mass with them
+	HIDDEN_SKIP = "skip"
+	// Keep their mass and contacts but do not draw them
+	HIDDEN_COLLISION = "collision"
+	// Keep them as if they were shown
+	HIDDEN_KEEP = "keep"
+)
+
+var HiddenPolicies = []string{HIDDEN_SKIP, HIDDEN_COLLISION, HIDDEN_KEEP}
+
+// Choices made while reading the assembly definition
+type LoadOptions struct {
+	// One of StandardContentPolicies, skip when empty
+	StandardContent string `json:"standard_content"`
+	// One of HiddenPolicies, collision when empty
+	Hidden string `json:"hidden"`
+}
+
+func DefaultLoadOptions() LoadOptions {
+	return LoadOptions{StandardContent: STANDARD_CONTENT_SKIP, Hidden: HIDDEN_COLLISION}
+}
+
+func (o LoadOptions) skipStandardContent() bool {
+	return o.StandardContent == "" || o.StandardContent == STANDARD_CONTENT_SKIP
+}
+
+// Hidden occurrences drawn nowhere but kept for their mass and contacts
+func (o LoadOptions) HiddenCollisionOnly() bool {
+	return o.Hidden == "" || o.Hidden == HIDDEN_COLLISION
+}
@@ -0,0 +1,121 @@
+package assembly
+
+import (
+	"math"
+
+	"github.com/ungerik/go3d/float64/mat4"
+	"github.com/ungerik/go3d/float64/quaternion"
+	"github.com/ungerik/go3d/float64/vec3"
+	"github.com/ungerik/go3d/float64/vec4"
+)
+
+// Rigid transform, rotating by Quaternion and then translating
+type Transform struct {
+	Translation vec3.T
+	Quaternion  quaternion.T
+}
+
+func IdentityTransform() Transform {
+	return Transform{Quaternion: quaternion.Ident}
+}
+
+func NewTransform(m mat4.T) Transform {
+	return Transform{
+		Translation: vec3.T{m.Get(3, 0), m.Get(3, 1), m.Get(3, 2)},
+		Quaternion:  RotationQuaternion(&m),
+	}
+}
+
+// Quaternion of the rotation in the upper 3x3 block. go3d's mat4.Quaternion
+// includes the homogeneous 1 in the trace and only works for w far from 0,
+// so this picks the largest component first instead.
+func RotationQuaternion(m *mat4.T) quaternion.T {
+	r := func(row, col int) float64 {
+		return m.Get(col, row)
+	}
+	var q quaternion.T
+	trace := r(0, 0) + r(1, 1) + r(2, 2)
+	switch {
+	case trace > 0:
+		s := math.Sqrt(trace+1) * 2
+		q = quaternion.T{(r(2, 1) - r(1, 2)) / s, (r(0, 2) - r(2, 0)) / s, (r(1, 0) - r(0, 1)) / s, s / 4}
+	case r(0, 0) > r(1, 1) && r(0, 0) > r(2, 2):
+		s := math.Sqrt(1+r(0, 0)-r(1, 1)-r(2, 2)) * 2
+		q = quaternion.T{s / 4, (r(0, 1) + r(1, 0)) / s, (r(0, 2) + r(2, 0)) / s, (r(2, 1) - r(1, 2)) / s}
+	case r(1, 1) > r(2, 2):
+		s := math.Sqrt(1+r(1, 1)-r(0, 0)-r(2, 2)) * 2
+		q = quaternion.T{(r(0, 1) + r(1, 0)) / s, s / 4, (r(1, 2) + r(2, 1)) / s, (r(0, 2) - r(2, 0)) / s}
+	default:
+		s := math.Sqrt(1+r(2, 2)-r(0, 0)-r(1, 1)) * 2
+		q = quaternion.T{(r(0, 2) + r(2, 0)) / s, (r(1, 2) + r(2, 1)) / s, s / 4, (r(1, 0) - r(0, 1)) / s}
+	}
+	return CanonicalQuaternion(q)
+}
+
+// Unit quaternion with w >= 0, or the first non-zero component positive when
+// w is 0. q and -q are the same rotation, so this makes the output of equal
+// rotations identical.
+func CanonicalQuaternion(q quaternion.T) quaternion.T {
+	q = q.Normalized()
+	for _, i := range []int{3, 0, 1, 2} {
+		if q[i] > 0 {
+			return q
+		}
+		if q[i] < 0 {
+			return q.Negated()
+		}
+	}
+	return q
+}
+
+// Onshape sends 4x4 matrices row by row, with the translation in the last
+// column, while go3d matrices are indexed by column first
+func TransformFromArray(arr []float64) Transform {
+	return NewTransform(mat4.T{
+		vec4.T{arr[0], arr[4], arr[8], arr[12]},
+		vec4.T{arr[1], arr[5], arr[9], arr[13]},
+		vec4.T{arr[2], arr[6], arr[10], arr[14]},
+		vec4.T{arr[3], arr[7], arr[11], arr[15]},
+	})
+}
+
+func (t Transform) Inverse() Transform {
+	q := CanonicalQuaternion(t.Quaternion.Inverted())
+	translation := q.RotatedVec3(&t.Translation)
+	return Transform{
+		Translation: *translation.Invert(),
+		Quaternion:  q,
+	}
+}
+
+// Applies child first and then t
+func (t Transform) Mul(child Transform) Transform {
+	translation := t.Quaternion.RotatedVec3(&child.Translation)
+	return Transform{
+		Translation: *translation.Add(&t.Translation),
+		Quaternion:  CanonicalQuaternion(quaternion.Mul(&t.Quaternion, &child.Quaternion)),
+	}
+}
+
+// Transform in the frame of parent, for transforms both given in the same frame
+func (t Transform) RelativeTo(parent Transform) Transform {
+	return parent.Inverse().Mul(t)
+}
+
+// Occurrence transforms in the assembly definition are in the frame of the
+// root assembly, while MJCF places each body relative to its parent
+func makeRelative(occ Occurrence, parent Transform) Occurrence {
+	switch o := occ.(type) {
+	case *AssemblyOccurrence:
+		world := o.Transform
+		o.Transform = world.RelativeTo(parent)
+		for i, child := range o.Children {
+			o.Children[i] = makeRelative(child, world)
+		}
+		return o
+	case *PartOccurrence:
+		o.Transform = o.Transform.RelativeTo(parent)
+		return o
+	}
+	return occ
+}
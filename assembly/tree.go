@@ -0,0 +1,185 @@
+package assembly
+
+import (
+	"fmt"
+	"strings"
+
+	onshapeapi "github.com/onshape-public/go-client/onshape"
+	"onshape-mcjf-exporter/onshape"
+)
+
+type partKey struct {
+	path onshape.ElementPath
+	id   string
+}
+
+// Instance with the part or sub-assembly it references, looked up once per
+// instance instead of once per occurrence
+type resolvedInstance struct {
+	*onshapeapi.BTAssemblyInstanceInfo
+	part     *PartInfo
+	assembly *AssemblyInfo
+	// Returned only when an occurrence of the instance is built, skipped
+	// standard content is missing from the parts on purpose
+	err error
+}
+
+// Builds the occurrence tree in one pass over the occurrences of the
+// definition, with instances, parts, sub-assemblies and occurrences indexed
+// up front
+type treeBuilder struct {
+	options LoadOptions
+	// Instances of the root assembly and of each sub-assembly by id
+	rootInstances map[string]*resolvedInstance
+	subInstances  map[*AssemblyInfo]map[string]*resolvedInstance
+	// Occurrences of the definition and the tree built so far by pathKey, nil
+	// for skipped occurrences
+	occurrences map[string]*onshapeapi.BTAssemblyOccurrenceInfo
+	nodes       map[string]Occurrence
+	roots       []Occurrence
+}
+
+// Instance ids from the root joined with '/'
+func pathKey(path []string) string {
+	return strings.Join(path, "/")
+}
+
+func elementPath(did string, microversion string, eid string, configuration string) onshape.ElementPath {
+	return onshape.ElementPath{DocumentId: did, WVM: "m", WVMId: microversion, ElementId: eid, Configuration: configuration}
+}
+
+func resolveInstances(instances []onshapeapi.BTAssemblyInstanceInfo, parts map[partKey]*PartInfo, assemblies map[onshape.ElementPath]*AssemblyInfo) map[string]*resolvedInstance {
+	index := make(map[string]*resolvedInstance, len(instances))
+	for i := range instances {
+		instance := &instances[i]
+		resolved := &resolvedInstance{BTAssemblyInstanceInfo: instance}
+		path := elementPath(instance.GetDocumentId(), instance.GetDocumentMicroversion(), instance.GetElementId(), instance.GetConfiguration())
+		switch instance.GetType() {
+		case onshapeapi.BTAssemblyInstanceTypeAssembly:
+			if resolved.assembly = assemblies[path]; resolved.assembly == nil {
+				resolved.err = &MissingPartError{Path: path}
+			}
+		case onshapeapi.BTAssemblyInstanceTypePart:
+			if resolved.part = parts[partKey{path, instance.GetPartId()}]; resolved.part == nil {
+				resolved.err = &MissingPartError{Path: path, PartId: instance.GetPartId()}
+			}
+		}
+		index[instance.GetId()] = resolved
+	}
+	return index
+}
+
+// Occurrence tree of the definition with each transform relative to its
+// parent, top level occurrences and children in the order of the definition
+func BuildOccurrences(assemblyDef *onshapeapi.BTAssemblyDefinitionInfo, partInfoList []PartInfo, assemblyInfoList []AssemblyInfo, options LoadOptions) ([]Occurrence, error) {
+	parts := make(map[partKey]*PartInfo, len(partInfoList))
+	for i := range partInfoList {
+		parts[partKey{partInfoList[i].Path, partInfoList[i].Id}] = &partInfoList[i]
+	}
+	assemblies := make(map[onshape.ElementPath]*AssemblyInfo, len(assemblyInfoList))
+	for i := range assemblyInfoList {
+		assemblies[assemblyInfoList[i].Path] = &assemblyInfoList[i]
+	}
+
+	occurrences := assemblyDef.RootAssembly.Occurrences
+	b := &treeBuilder{
+		options:       options,
+		rootInstances: resolveInstances(assemblyDef.RootAssembly.Instances, parts, assemblies),
+		subInstances:  make(map[*AssemblyInfo]map[string]*resolvedInstance, len(assemblyDef.SubAssemblies)),
+		occurrences:   make(map[string]*onshapeapi.BTAssemblyOccurrenceInfo, len(occurrences)),
+		nodes:         make(map[string]Occurrence, len(occurrences)),
+	}
+	for _, sub := range assemblyDef.SubAssemblies {
+		path := elementPath(sub.GetDocumentId(), sub.GetDocumentMicroversion(), sub.GetElementId(), sub.GetConfiguration())
+		if assembly := assemblies[path]; assembly != nil {
+			b.subInstances[assembly] = resolveInstances(sub.Instances, parts, assemblies)
+		}
+	}
+	keys := make([]string, len(occurrences))
+	for i := range occurrences {
+		keys[i] = pathKey(occurrences[i].Path)
+		b.occurrences[keys[i]] = &occurrences[i]
+	}
+
+	for i, occ := range occurrences {
+		if len(occ.Path) == 0 {
+			continue
+		}
+		if _, err := b.occurrence(occ.Path, keys[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, occ := range b.roots {
+		b.roots[i] = makeRelative(occ, IdentityTransform())
+	}
+	return b.roots, nil
+}
+
+// Occurrence at path with pathKey key, built along with its parents on first
+// use. Nil for occurrences that are skipped or whose parent is.
+func (b *treeBuilder) occurrence(path []string, key string) (Occurrence, error) {
+	if occ, ok := b.nodes[key]; ok {
+		return occ, nil
+	}
+	b.nodes[key] = nil
+
+	id := path[len(path)-1]
+	instances := b.rootInstances
+	var parent *AssemblyOccurrence
+	hidden := false
+	if len(path) > 1 {
+		occ, err := b.occurrence(path[:len(path)-1], key[:len(key)-len(id)-1])
+		if err != nil {
+			return nil, err
+		}
+		if parent, _ = occ.(*AssemblyOccurrence); parent == nil {
+			return nil, nil
+		}
+		instances = b.subInstances[parent.Assembly]
+		hidden = parent.Hidden
+	}
+
+	instance := instances[id]
+	info := b.occurrences[key]
+	if instance == nil || info == nil {
+		return nil, nil
+	}
+	if instance.GetIsStandardContent() && b.options.skipStandardContent() {
+		return nil, nil
+	}
+	// Suppressed instances are not part of the design at all
+	if instance.GetSuppressed() {
+		return nil, nil
+	}
+	hidden = hidden || info.GetHidden()
+	if hidden && b.options.Hidden == HIDDEN_SKIP {
+		return nil, nil
+	}
+	if instance.err != nil {
+		return nil, instance.err
+	}
+	if len(info.Transform) != 16 {
+		return nil, fmt.Errorf("occurrence %s has a transform of %d values, expected 16", key, len(info.Transform))
+	}
+
+	// path may be a prefix of a child's path
+	base := BaseOccurrence{Transform: TransformFromArray(info.Transform), Id: id, Path: append([]string(nil), path...), Name: instance.GetName(), Hidden: hidden}
+	var occ Occurrence
+	switch {
+	case instance.assembly != nil:
+		occ = &AssemblyOccurrence{BaseOccurrence: base, Assembly: instance.assembly, Children: make([]Occurrence, 0)}
+	case instance.part != nil:
+		occ = &PartOccurrence{BaseOccurrence: base, Part: instance.part}
+	default:
+		return nil, nil
+	}
+
+	b.nodes[key] = occ
+	if parent != nil {
+		parent.AddChild(occ)
+	} else {
+		b.roots = append(b.roots, occ)
+	}
+	return occ, nil
+}
@@ -0,0 +1,216 @@
+package assembly
+
+import (
+	"math"
+	"testing"
+
+	onshapeapi "github.com/onshape-public/go-client/onshape"
+	"github.com/ungerik/go3d/float64/quaternion"
+	"github.com/ungerik/go3d/float64/vec3"
+	"onshape-mcjf-exporter/onshapetest"
+)
+
+// Large enough to stand in for a production assembly, 56660 occurrences
+const (
+	SYNTHETIC_DEPTH = 3
+	SYNTHETIC_WIDTH = 10
+	SYNTHETIC_PARTS = 50
+)
+
+// Part and sub-assembly infos of a definition as NewModelData loads them
+func definitionInfos(def *onshapeapi.BTAssemblyDefinitionInfo) ([]PartInfo, []AssemblyInfo) {
+	parts := make([]PartInfo, 0, len(def.Parts))
+	for _, part := range def.Parts {
+		parts = append(parts, PartInfo{
+			Id:   part.GetPartId(),
+			Name: part.GetPartId(),
+			Path: elementPath(part.GetDocumentId(), part.GetDocumentMicroversion(), part.GetElementId(), part.GetConfiguration()),
+		})
+	}
+	assemblies := make([]AssemblyInfo, 0, len(def.SubAssemblies))
+	for _, sub := range def.SubAssemblies {
+		assemblies = append(assemblies, AssemblyInfo{
+			Name: sub.GetElementId(),
+			Path: elementPath(sub.GetDocumentId(), sub.GetDocumentMicroversion(), sub.GetElementId(), sub.GetConfiguration()),
+		})
+	}
+	return parts, assemblies
+}
+
+// Calls visit for every occurrence of the tree, parents first
+func walkOccurrences(occs []Occurrence, visit func(Occurrence)) {
+	for _, occ := range occs {
+		visit(occ)
+		if a, ok := occ.(*AssemblyOccurrence); ok {
+			walkOccurrences(a.Children, visit)
+		}
+	}
+}
+
+func TestBuildOccurrencesKeepsEveryOccurrence(t *testing.T) {
+	def := onshapetest.SyntheticAssembly("d1", SYNTHETIC_DEPTH, SYNTHETIC_WIDTH, SYNTHETIC_PARTS)
+	parts, assemblies := definitionInfos(&def)
+
+	roots, err := BuildOccurrences(&def, parts, assemblies, DefaultLoadOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sub-assembly nodes on every level below the root, and the parts of the
+	// root and of every sub-assembly node
+	subAssemblies := 0
+	for level, n := 1, 1; level <= SYNTHETIC_DEPTH; level++ {
+		n *= SYNTHETIC_WIDTH
+		subAssemblies += n
+	}
+	want := subAssemblies + (subAssemblies+1)*SYNTHETIC_PARTS
+	if len(def.RootAssembly.Occurrences) != want {
+		t.Fatalf("synthetic assembly has %d occurrences, want %d", len(def.RootAssembly.Occurrences), want)
+	}
+
+	paths := make(map[string]bool, want)
+	for _, occ := range def.RootAssembly.Occurrences {
+		paths[pathKey(occ.Path)] = true
+	}
+	count := 0
+	walkOccurrences(roots, func(occ Occurrence) {
+		count++
+		key := pathKey(occ.GetPath())
+		if !paths[key] {
+			t.Errorf("occurrence %s is built twice or not in the definition", key)
+		}
+		delete(paths, key)
+	})
+	if count != want {
+		t.Errorf("built %d occurrences, want %d", count, want)
+	}
+	for key := range paths {
+		t.Errorf("occurrence %s is missing", key)
+	}
+}
+
+func BenchmarkBuildOccurrences(b *testing.B) {
+	def := onshapetest.SyntheticAssembly("d1", SYNTHETIC_DEPTH, SYNTHETIC_WIDTH, SYNTHETIC_PARTS)
+	parts, assemblies := definitionInfos(&def)
+	options := DefaultLoadOptions()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := BuildOccurrences(&def, parts, assemblies, options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRepeatedInstances(t *testing.T) {
+	tests := []struct {
+		name   string
+		legs   int
+		feet   int
+		plates int
+	}{
+		{"sub-assembly instanced three times", 3, 1, 0},
+		{"part instanced four times", 0, 0, 4},
+		{"patterned part in a repeated sub-assembly", 2, 3, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			def := onshapetest.RepeatedAssembly("d1", test.legs, test.feet, test.plates)
+			parts, assemblies := definitionInfos(&def)
+			roots, err := BuildOccurrences(&def, parts, assemblies, DefaultLoadOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(roots) != test.legs+test.plates {
+				t.Fatalf("%d top level occurrences, want %d", len(roots), test.legs+test.plates)
+			}
+
+			paths := make(map[string]bool)
+			partOccurrences := 0
+			walkOccurrences(roots, func(occ Occurrence) {
+				key := pathKey(occ.GetPath())
+				if paths[key] {
+					t.Errorf("path %s is shared", key)
+				}
+				paths[key] = true
+				if p, ok := occ.(*PartOccurrence); ok {
+					partOccurrences++
+					// Every instance refers to the one part
+					if p.Part != &parts[0] {
+						t.Errorf("%s refers to its own copy of the part", key)
+					}
+				}
+			})
+			if want := test.legs*test.feet + test.plates; partOccurrences != want {
+				t.Errorf("%d part occurrences, want %d", partOccurrences, want)
+			}
+
+			// Legs are placed in the root, feet relative to their leg
+			for i, occ := range roots[:test.legs] {
+				leg := occ.(*AssemblyOccurrence)
+				checkTransform(t, leg, float64(i+1), 0, 0, turnedZ)
+				for k, foot := range leg.Children {
+					checkTransform(t, foot, 0, float64(k+1), 0, IdentityTransform().Quaternion)
+				}
+			}
+			for k, plate := range roots[test.legs:] {
+				checkTransform(t, plate, 0, 0, float64(k+1), IdentityTransform().Quaternion)
+			}
+		})
+	}
+}
+
+// Rotation of the legs of onshapetest.RepeatedAssembly, 90° about z
+var turnedZ = quaternion.FromZAxisAngle(math.Pi / 2)
+
+func checkTransform(t *testing.T, occ Occurrence, x float64, y float64, z float64, rotation quaternion.T) {
+	t.Helper()
+	const epsilon = 1e-9
+	transform := occ.GetTransform()
+	translation := vec3.T{x, y, z}
+	got := transform.Quaternion
+	if vec3.Distance(&transform.Translation, &translation) > epsilon || math.Abs(quaternion.Dot(&got, &rotation)) < 1-epsilon {
+		t.Errorf("%s at %v turned %v, want %v turned %v", pathKey(occ.GetPath()), transform.Translation, got, translation, rotation)
+	}
+}
+
+func TestSuppressedAndHiddenSubAssemblies(t *testing.T) {
+	tests := []struct {
+		policy string
+		// Paths of the occurrences built, and whether each is hidden
+		want map[string]bool
+	}{
+		{HIDDEN_SKIP, map[string]bool{"leg3": false, "leg3/foot1": false, "plate1": false}},
+		{HIDDEN_COLLISION, map[string]bool{"leg1": true, "leg1/foot1": true, "leg3": false, "leg3/foot1": false, "plate1": false}},
+		{HIDDEN_KEEP, map[string]bool{"leg1": true, "leg1/foot1": true, "leg3": false, "leg3/foot1": false, "plate1": false}},
+	}
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			// Leg 1 hidden, leg 2 suppressed, both with their feet
+			def := onshapetest.RepeatedAssembly("d1", 3, 1, 1)
+			def.RootAssembly.Occurrences[0].Hidden = ptr(true)
+			def.RootAssembly.Instances[1].Suppressed = ptr(true)
+			parts, assemblies := definitionInfos(&def)
+			options := DefaultLoadOptions()
+			options.Hidden = test.policy
+
+			roots, err := BuildOccurrences(&def, parts, assemblies, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]bool)
+			walkOccurrences(roots, func(occ Occurrence) {
+				got[pathKey(occ.GetPath())] = occ.IsHidden()
+			})
+			if len(got) != len(test.want) {
+				t.Errorf("built %v, want %v", got, test.want)
+			}
+			for key, hidden := range test.want {
+				if h, ok := got[key]; !ok || h != hidden {
+					t.Errorf("%s built %v hidden %v, want hidden %v", key, ok, h, hidden)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	exporter "onshape-mcjf-exporter"
+	"onshape-mcjf-exporter/mjcf"
+	"onshape-mcjf-exporter/onshape"
+)
+
+const DEFAULT_CONFIG_PATH = ".onshape_client_config.json"
+
+const (
+	VERBOSITY_QUIET = 0
+	VERBOSITY_INFO  = 1
+	VERBOSITY_DEBUG = 2
+)
+
+// Flags shared by the commands that talk to Onshape
+type commonFlags struct {
+	config      string
+	format      string
+	units       string
+	verbosity   int
+	concurrency int
+	offline     bool
+	record      string
+	replay      string
+	server      string
+	did         string
+	wvm         string
+	wvmid       string
+	eid         string
+	pin         string
+	configure   string
+	// Values of configure, applied over the configuration of the config
+	// since only the server can tell whether a name and an id refer to the
+	// same parameter
+	configuration map[string]string
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.config, "config", DEFAULT_CONFIG_PATH, "path of the exporter config file")
+	fs.StringVar(&c.format, "format", "text", "output format of reports: text or json")
+	fs.StringVar(&c.units, "units", "", "length unit of the model and its meshes, m or mm, overrides output.length_unit")
+	fs.IntVar(&c.verbosity, "v", VERBOSITY_INFO, "verbosity: 0 quiet, 1 progress, 2 debug HTTP traffic")
+	fs.IntVar(&c.concurrency, "concurrency", 0, "number of meshes downloaded in parallel, overrides the config (default 4)")
+	fs.BoolVar(&c.offline, "offline", false, "rebuild the model from cached Onshape responses without any network access")
+	fs.StringVar(&c.record, "record", "", "save every Onshape API response as a fixture in this directory")
+	fs.StringVar(&c.replay, "replay", "", "serve Onshape API responses from fixtures in this directory")
+	fs.StringVar(&c.server, "server", "", "Onshape server for -did, defaults to base_url in the config or " + onshape.DEFAULT_SERVER_URL)
+	fs.StringVar(&c.did, "did", "", "document id, instead of a url")
+	fs.StringVar(&c.wvm, "wvm", "w", "with -did: w (workspace), v (version) or m (microversion)")
+	fs.StringVar(&c.wvmid, "wvmid", "", "with -did: workspace, version or microversion id")
+	fs.StringVar(&c.eid, "eid", "", "with -did: element id of the assembly")
+	fs.StringVar(&c.configure, "configuration", "", "configuration parameters as name=value;name=value, overriding the url and config")
+	fs.StringVar(&c.pin, "pin", "", "export from an immutable reference: " + exporter.PIN_CURRENT + " for the current microversion, or a version name or id")
+}
+
+func (c *commonFlags) logf(format string, args ...any) {
+	if c.verbosity >= VERBOSITY_INFO {
+		fmt.Fprintf(os.Stderr, format + "\n", args...)
+	}
+}
+
+// Layers the global config, the project config and the flags, in that
+// order, then validates the result and resolves the credentials
+func (c *commonFlags) loadConfig() (*exporter.ExporterConfig, error) {
+	if c.format != "text" && c.format != "json" {
+		return nil, &usageError{fmt.Sprintf("unknown format %q", c.format)}
+	}
+
+	config, err := loadLayeredConfig(c.config)
+	if err != nil {
+		return nil, err
+	}
+	if c.units != "" {
+		// The meshes follow the model
+		config.Output.Length = c.units
+		config.StlExportOptions.Units = ""
+	}
+	if c.concurrency != 0 {
+		config.StlExportOptions.Concurrency = c.concurrency
+	}
+	c.configuration, err = onshape.ParseConfiguration(c.configure)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.ResolveCredentials(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Merges the project config at path over the global one. The default project
+// config may be missing, settings can come from the global config and the
+// environment alone.
+func loadLayeredConfig(path string) (*exporter.ExporterConfig, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) && path == DEFAULT_CONFIG_PATH {
+		path = ""
+	}
+	return exporter.LoadConfig(exporter.GlobalConfigPath(), path)
+}
+
+// Resolves the document and exporter options from the config files and flags
+func (c *commonFlags) load(args []string) (*onshape.Element, exporter.Options, error) {
+	config, err := c.loadConfig()
+	if err != nil {
+		return nil, exporter.Options{}, err
+	}
+
+	var element *onshape.Element
+	switch {
+	case len(args) > 0:
+		element, err = onshape.ElementFromURL(args[0])
+	case c.did != "":
+		server := c.server
+		if server == "" && config.OnshapeClient.BaseUrl != "" {
+			server = config.OnshapeClient.BaseUrl
+			if config.BaseElement != nil {
+				server = config.BaseElement.ServerURL
+			}
+		}
+		element, err = onshape.NewElement(server, c.did, c.wvm, c.wvmid, c.eid)
+	case config.BaseElement != nil:
+		element = config.BaseElement
+	default:
+		return nil, exporter.Options{}, &usageError{"missing document url or -did"}
+	}
+	if err != nil {
+		return nil, exporter.Options{}, err
+	}
+
+	opts := config.Options()
+	opts.Offline = c.offline
+	if config.OAuth != nil && !c.offline {
+		opts.TokenSource, err = onshape.NewTokenSource(context.Background(), *config.OAuth)
+		if err != nil {
+			return nil, exporter.Options{}, err
+		}
+	}
+	opts.Debug = c.verbosity >= VERBOSITY_DEBUG
+	opts.Pin = c.pin
+	opts.ConfigurationOverrides = c.configuration
+	if c.record != "" {
+		opts.Transport = onshape.NewFixtureTransport(c.record, onshape.FixtureRecord, http.DefaultTransport)
+	} else if c.replay != "" {
+		opts.Transport = onshape.NewFixtureTransport(c.replay, onshape.FixtureReplay, http.DefaultTransport)
+	}
+
+	return element, opts, nil
+}
+
+// Parses flags placed before, between or after the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func newFlagSet(name string, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: onshape-mjcf-exporter %s %s\n\nFlags:\n", name, synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func writeJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func runExport(args []string) error {
+	var common commonFlags
+	fs := newFlagSet("export", "[url | -did ... -eid ...] -o <dir>")
+	common.register(fs)
+	output := fs.String("o", ".", "output directory for the model and its meshes")
+	each := fs.String("each", "", "export every option of this configuration list to its own subdirectory of -o")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	element, opts, err := common.load(positional)
+	if err != nil {
+		return err
+	}
+	opts.OutputDir = *output
+
+	if *each != "" {
+		common.logf("exporting every %s of %s", *each, element.URL())
+		results, err := exporter.ExportConfigurations(context.Background(), element, *each, opts)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			common.logf("wrote %s with %d meshes in %s", result.ModelPath, len(result.MeshParts), result.MeshDir)
+		}
+		return nil
+	}
+
+	common.logf("exporting %s", element.URL())
+	result, err := exporter.ExportElement(context.Background(), element, opts)
+	if err != nil {
+		return err
+	}
+	common.logf("wrote %s with %d meshes in %s", result.ModelPath, len(result.MeshParts), result.MeshDir)
+	return nil
+}
+
+func runInspect(args []string) error {
+	var common commonFlags
+	fs := newFlagSet("inspect", "[url | -did ... -eid ...]")
+	common.register(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	element, opts, err := common.load(positional)
+	if err != nil {
+		return err
+	}
+
+	_, model, err := exporter.LoadElement(context.Background(), element, opts)
+	if err != nil {
+		return err
+	}
+
+	if common.format == "json" {
+		return writeJSON(occurrenceTree(model.Occurrences))
+	}
+	fmt.Println(model.DocumentInfo.GetName())
+	printOccurrences(model.Occurrences, 1)
+	return nil
+}
+
+func runMeshes(args []string) error {
+	var common commonFlags
+	fs := newFlagSet("meshes", "[url | -did ... -eid ...] -o <dir>")
+	common.register(fs)
+	output := fs.String("o", exporter.MESH_DIR_NAME, "output directory for the meshes")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	element, opts, err := common.load(positional)
+	if err != nil {
+		return err
+	}
+
+	// Same parts and file names as in an exported model
+	common.logf("downloading meshes of %s to %s", element.URL(), *output)
+	parts, err := exporter.ExportMeshes(context.Background(), element, *output, opts)
+	if err != nil {
+		return err
+	}
+	common.logf("wrote %d meshes in %s", len(parts), *output)
+	return nil
+}
+
+func runValidate(args []string) error {
+	fs := newFlagSet("validate", "<file.xml>")
+	format := fs.String("format", "text", "output format: text or json")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return &usageError{"validate needs exactly one model file"}
+	}
+
+	root, err := mjcf.ParseFile(positional[0])
+	if err != nil {
+		return err
+	}
+
+	problems := mjcf.Validate(root, filepath.Dir(positional[0]))
+	if *format == "json" {
+		messages := make([]string, 0, len(problems))
+		for _, p := range problems {
+			messages = append(messages, p.Error())
+		}
+		if err := writeJSON(messages); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &exitError{EXIT_INVALID}
+	}
+	return nil
+}
+
+func runDiff(args []string) error {
+	fs := newFlagSet("diff", "<a.xml> <b.xml>")
+	format := fs.String("format", "text", "output format: text or json")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return &usageError{"diff needs exactly two model files"}
+	}
+
+	a, err := mjcf.ParseFile(positional[0])
+	if err != nil {
+		return err
+	}
+	b, err := mjcf.ParseFile(positional[1])
+	if err != nil {
+		return err
+	}
+
+	changes := mjcf.Diff(a, b)
+	if *format == "json" {
+		if err := writeJSON(changes); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+	}
+
+	if len(changes) > 0 {
+		return &exitError{EXIT_DIFFERENT}
+	}
+	return nil
+}
+
+// Prints the config after layering and validation, with secrets redacted
+func runConfig(args []string) error {
+	var common commonFlags
+	fs := newFlagSet("config", "")
+	common.register(fs)
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	config, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	return writeJSON(config.Redacted())
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"onshape-mcjf-exporter/assembly"
+)
+
+type occurrenceNode struct {
+	Id          string           `json:"id"`
+	Path        []string         `json:"path"`
+	Type        string           `json:"type"`
+	Name        string           `json:"name"`
+	Hidden      bool             `json:"hidden,omitempty"`
+	Translation [3]float64       `json:"translation"`
+	Quaternion  [4]float64       `json:"quaternion"`
+	Children    []occurrenceNode `json:"children,omitempty"`
+}
+
+func newOccurrenceNode(occ assembly.Occurrence) occurrenceNode {
+	t := occ.GetTransform()
+	node := occurrenceNode{
+		Id:          occ.GetId(),
+		Path:        occ.GetPath(),
+		Hidden:      occ.IsHidden(),
+		Translation: t.Translation,
+		// w, x, y, z like MJCF
+		Quaternion: [4]float64{t.Quaternion[3], t.Quaternion[0], t.Quaternion[1], t.Quaternion[2]},
+	}
+
+	switch o := occ.(type) {
+	case *assembly.AssemblyOccurrence:
+		node.Type = "assembly"
+		node.Name = o.Assembly.Name
+		node.Children = occurrenceTree(o.Children)
+	case *assembly.PartOccurrence:
+		node.Type = "part"
+		node.Name = o.Part.Name
+	}
+	return node
+}
+
+func occurrenceTree(occurrences []assembly.Occurrence) []occurrenceNode {
+	nodes := make([]occurrenceNode, 0, len(occurrences))
+	for _, occ := range occurrences {
+		nodes = append(nodes, newOccurrenceNode(occ))
+	}
+	return nodes
+}
+
+func printOccurrences(occurrences []assembly.Occurrence, depth int) {
+	for _, node := range occurrenceTree(occurrences) {
+		printOccurrenceNode(node, depth)
+	}
+}
+
+func printOccurrenceNode(node occurrenceNode, depth int) {
+	t := node.Translation
+	kind := node.Type
+	if node.Hidden {
+		kind += " hidden"
+	}
+	fmt.Printf("%s%s [%s %s] pos %g %g %g\n", strings.Repeat("  ", depth), node.Name, kind, strings.Join(node.Path, "/"), t[0], t[1], t[2])
+	for _, child := range node.Children {
+		printOccurrenceNode(child, depth + 1)
+	}
+}
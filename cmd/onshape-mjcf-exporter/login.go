@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"onshape-mcjf-exporter/onshape"
+)
+
+// How long login waits for the browser flow to finish
+const DEFAULT_LOGIN_TIMEOUT = 5 * time.Minute
+
+func loadOAuthConfig(path string) (onshape.OAuthConfig, error) {
+	config, err := loadLayeredConfig(path)
+	if err != nil {
+		return onshape.OAuthConfig{}, err
+	}
+	if err := config.Validate(); err != nil {
+		return onshape.OAuthConfig{}, err
+	}
+	if config.OAuth == nil {
+		return onshape.OAuthConfig{}, &usageError{"no oauth section with a client_id in " + path}
+	}
+	return *config.OAuth, nil
+}
+
+// Opens url in the default browser, the url is printed as well in case that fails
+func openBrowser(url string) error {
+	fmt.Fprintln(os.Stderr, "opening", url)
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, "open the url above in a browser to continue")
+	}
+	return nil
+}
+
+func runLogin(args []string) error {
+	fs := newFlagSet("login", "")
+	config := fs.String("config", DEFAULT_CONFIG_PATH, "path of the exporter config file")
+	timeout := fs.Duration("timeout", DEFAULT_LOGIN_TIMEOUT, "give up when the login is not completed in the browser within this time")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	oauthConfig, err := loadOAuthConfig(*config)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if _, err := onshape.Login(ctx, oauthConfig, openBrowser); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w: login not completed within %s", onshape.ErrAuth, *timeout)
+		}
+		return err
+	}
+
+	store, err := oauthConfig.TokenStore()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "logged in, token saved to", store.Path)
+	return nil
+}
+
+func runLogout(args []string) error {
+	fs := newFlagSet("logout", "")
+	config := fs.String("config", DEFAULT_CONFIG_PATH, "path of the exporter config file")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	oauthConfig, err := loadOAuthConfig(*config)
+	if err != nil {
+		return err
+	}
+	store, err := oauthConfig.TokenStore()
+	if err != nil {
+		return err
+	}
+	return store.Delete()
+}
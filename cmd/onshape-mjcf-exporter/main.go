@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	exporter "onshape-mcjf-exporter"
+	"onshape-mcjf-exporter/assembly"
+	"onshape-mcjf-exporter/onshape"
+)
+
+const (
+	EXIT_OK           = 0
+	EXIT_ERROR        = 1
+	EXIT_CONFIG       = 2
+	EXIT_AUTH         = 3
+	EXIT_NOT_FOUND    = 4
+	EXIT_RATE_LIMITED = 5
+	EXIT_MISSING_PART = 6
+	EXIT_INVALID      = 7
+	EXIT_DIFFERENT    = 8
+)
+
+const usage = `Usage: onshape-mjcf-exporter <command> [flags] [args]
+
+Commands:
+  export <url> -o <dir>     export an assembly to an MJCF model and its meshes
+  inspect <url>             print the occurrence tree of an assembly
+  meshes <url> -o <dir>     download the STL meshes of every part
+  validate <file.xml>       check a model for broken references and bad values
+  diff <a.xml> <b.xml>      print the structural differences between two models
+  login                     log in to Onshape with the oauth application in the config
+  logout                    forget the token saved by login
+  config                    print the effective config after merging and validation
+
+The url may be omitted when base_url is set in the config file.
+Run "onshape-mjcf-exporter <command> -h" for the flags of a command.
+
+Exit codes: 1 error, 2 bad usage or config, 3 authentication failed,
+4 not found, 5 rate limited, 6 missing part, 7 invalid model,
+8 models differ.
+`
+
+var commands = map[string]func(args []string) error{
+	"export":   runExport,
+	"inspect":  runInspect,
+	"meshes":   runMeshes,
+	"validate": runValidate,
+	"diff":     runDiff,
+	"login":    runLogin,
+	"logout":   runLogout,
+	"config":   runConfig,
+}
+
+// Error for a command line mistake, reported with the usage text
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// Error carrying its own exit code once the message has been printed
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// Print a readable message for err and exit with a code matching its kind
+func exitWithError(err error) {
+	code := EXIT_ERROR
+	hint := ""
+	var usageErr *usageError
+	var exitErr *exitError
+	switch {
+	case errors.As(err, &exitErr):
+		os.Exit(exitErr.code)
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(EXIT_OK)
+	case errors.As(err, &usageErr):
+		code = EXIT_CONFIG
+		hint = `run "onshape-mjcf-exporter help" for usage`
+	case errors.Is(err, onshape.ErrAuth):
+		code = EXIT_AUTH
+		hint = "check the access and secret keys in the config file, or run login again"
+	case errors.Is(err, onshape.ErrRateLimited):
+		code = EXIT_RATE_LIMITED
+		hint = "wait a while before exporting again"
+	case errors.Is(err, onshape.ErrNotFound):
+		code = EXIT_NOT_FOUND
+		hint = "check that the url points to an assembly you have access to"
+	case errors.Is(err, onshape.ErrMalformedURL), errors.Is(err, onshape.ErrNotCached):
+		code = EXIT_CONFIG
+	case errors.Is(err, exporter.ErrInvalidConfig):
+		code = EXIT_CONFIG
+		hint = "the config is layered from the global config, the project config and the flags"
+	case errors.Is(err, onshape.ErrInvalidConfiguration):
+		code = EXIT_CONFIG
+		hint = "configuration parameters and list options can be given by name or id"
+	case errors.Is(err, assembly.ErrMissingPart), errors.Is(err, assembly.ErrMissingId):
+		code = EXIT_MISSING_PART
+	}
+
+	fmt.Fprintln(os.Stderr, "error:", err)
+	if hint != "" {
+		fmt.Fprintln(os.Stderr, "hint:", hint)
+	}
+	os.Exit(code)
+}
+
+func main() {
+	// Library warnings and debug traffic go to stderr without timestamps
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(EXIT_CONFIG)
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		fmt.Print(usage)
+		return
+	}
+
+	run, ok := commands[name]
+	if !ok {
+		exitWithError(&usageError{fmt.Sprintf("unknown command %q", name)})
+	}
+	if err := run(os.Args[2:]); err != nil {
+		exitWithError(err)
+	}
+}
@@ -1,67 +1,631 @@
-package main
+package exporter
 
 import (
 	"os"
+	"bytes"
 	"encoding/json"
-	"net/url"
-	"strings"
 	"errors"
+	"fmt"
+	"math"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"onshape-mcjf-exporter/assembly"
+	"onshape-mcjf-exporter/mesh"
+	"onshape-mcjf-exporter/mjcf"
+	"onshape-mcjf-exporter/onshape"
 )
 
-type OnshapeClient struct {
-	BaseUrl   string `json:"base_url"`
-	SecretKey string `json:"secret_key"`
-	AccessKey string `json:"access_key"`
+// Schema version of the config files, those without "version" are read as 1
+const CONFIG_VERSION = 1
+
+// Name of the global config file without its extension
+const GLOBAL_CONFIG_NAME = "config"
+
+var ErrInvalidConfig = errors.New("invalid config")
+
+// Problem with one value of the config, e.g. "physics.timestep: must be positive"
+type ConfigError struct {
+	// File the value was read from, empty for the merged config
+	File string
+	// Field path as in the file, e.g. "parts[2].friction"
+	Path string
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	message := e.Message
+	if e.Path != "" {
+		message = e.Path + ": " + message
+	}
+	if e.File != "" {
+		message = e.File + ": " + message
+	}
+	return message
+}
+
+func (e *ConfigError) Is(target error) bool {
+	return target == ErrInvalidConfig
 }
 
-type StlExportOptions struct {
-	Units string `json:"units"`
-	Mode string `json:"mode"`
+type OutputConfig struct {
+	ModelFile string `json:"model_file"`
+	// Both relative to the output directory
+	MeshDir string `json:"mesh_dir"`
+	// length_unit, angle_unit and up_axis of the model and its meshes
+	mjcf.UnitOptions
+	// Write the export time into the header, which makes every export differ
+	Timestamp bool `json:"timestamp,omitempty"`
+}
+
+type MaterialsConfig struct {
+	Default mjcf.MaterialOptions `json:"default"`
+	// Onshape material names to physical materials, before the built-in table
+	Table []mjcf.MaterialRule `json:"table,omitempty"`
 }
 
 type ExporterConfig struct {
-	OnshapeClient OnshapeClient `json:"onshape_client"`
-	StlExportOptions StlExportOptions `json:"stl_export_options"`
-	BaseElement *OnshapeElement
+	Version int `json:"version"`
+	OnshapeClient onshape.Credentials `json:"onshape_client"`
+	// Log in as a user instead of using the API keys when set
+	OAuth *onshape.OAuthConfig `json:"oauth,omitempty"`
+	StlExportOptions mesh.ExportOptions `json:"stl_export_options"`
+	CacheDir string `json:"cache_dir,omitempty"`
+	// Configuration parameter values by name, e.g. {"Side": "Left"}
+	Configuration map[string]string `json:"configuration,omitempty"`
+	Output OutputConfig `json:"output"`
+	Assembly assembly.LoadOptions `json:"assembly"`
+	Physics mjcf.PhysicsOptions `json:"physics"`
+	Materials MaterialsConfig `json:"materials"`
+	Collision mjcf.CollisionOptions `json:"collision"`
+	Parts []mjcf.PartOverride `json:"parts,omitempty"`
+	Naming mjcf.NamingOptions `json:"naming"`
+	BaseElement *onshape.Element `json:"-"`
 }
 
-func OnshapeElementFromURL(baseUrl string) *OnshapeElement {
-	parseError := func(err error) {
-		fatalError("failed to parse onshape url", err)
+// Values of everything the config files leave out
+func DefaultConfig() *ExporterConfig {
+	model := mjcf.DefaultModelOptions()
+	return &ExporterConfig{
+		Version: CONFIG_VERSION,
+		// Units follow output.length_unit
+		StlExportOptions: mesh.ExportOptions{
+			Mode:        "binary",
+			Concurrency: 4,
+		},
+		Output: OutputConfig{
+			ModelFile:   MODEL_FILE_NAME,
+			MeshDir:     MESH_DIR_NAME,
+			UnitOptions: model.Units,
+		},
+		Assembly:  assembly.DefaultLoadOptions(),
+		Physics:   model.Physics,
+		Materials: MaterialsConfig{Default: model.DefaultMaterial},
+		Collision: model.Collision,
+		Naming:    model.Naming,
 	}
+}
 
-	u, err := url.Parse(baseUrl)
+// Config file shared by all projects, config.yaml, config.yml or config.json
+// in the user config directory, e.g. ~/.config/onshape-mjcf/config.yaml on
+// Linux. Empty when there is none.
+func GlobalConfigPath() string {
+	dir, err := os.UserConfigDir()
 	if err != nil {
-		parseError(err)
+		return ""
+	}
+	for _, ext := range []string{".yaml", ".yml", ".json"} {
+		p := filepath.Join(dir, onshape.CACHE_DIR_NAME, GLOBAL_CONFIG_NAME + ext)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// Reads one config file as a generic tree, YAML for .yaml and .yml files and
+// JSON otherwise, and checks it against the schema on its own so that errors
+// name the file they are in
+func readLayer(path string) (map[string]any, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
-	segments := strings.Split(u.Path[1:], "/")
-	if len(segments) != 6 {
-		parseError(errors.New("malformed url"))
+	layer := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(contents, &layer); err != nil {
+			return nil, &ConfigError{File: path, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+		}
+	default:
+		if err := json.Unmarshal(contents, &layer); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				line := bytes.Count(contents[:syntaxErr.Offset], []byte("\n")) + 1
+				return nil, &ConfigError{File: path, Message: fmt.Sprintf("line %d: %v", line, err)}
+			}
+			return nil, &ConfigError{File: path, Message: err.Error()}
+		}
 	}
 
-	return &OnshapeElement{
-		ServerURL: u.Scheme + "://" + u.Host + "/",
-		did:       segments[1],
-		wvm:       segments[2],
-		wvmid:     segments[3],
-		eid:       segments[5],
+	var scratch ExporterConfig
+	if err := decodeStrict(path, layer, &scratch); err != nil {
+		return nil, err
+	}
+	if scratch.Version != 0 && scratch.Version != CONFIG_VERSION {
+		return nil, &ConfigError{
+			File: path,
+			Path: "version",
+			Message: fmt.Sprintf("version %d is not supported, this exporter reads version %d", scratch.Version, CONFIG_VERSION),
+		}
 	}
+	return layer, nil
 }
 
-func LoadConfigFromFile(path string) *ExporterConfig {
-	contents, err := os.ReadFile(path)
+// Decodes a generic tree into config. The tree is first checked against the
+// schema so that every unknown field and value of the wrong type is reported,
+// joined with errors.Join, each a ConfigError with its path in file.
+func decodeStrict(file string, tree map[string]any, config *ExporterConfig) error {
+	var errs []error
+	checkSchema(file, "", tree, reflect.TypeOf(config).Elem(), &errs)
+	if len(errs) != 0 {
+		return errors.Join(errs...)
+	}
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return &ConfigError{File: file, Message: err.Error()}
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return &ConfigError{File: file, Message: strings.TrimPrefix(err.Error(), "json: ")}
+	}
+	return nil
+}
+
+// Field path as written in the errors, e.g. "parts[2].friction"
+func fieldPath(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// Config fields of a struct by their json name, including those of embedded
+// structs
+func schemaFields(t reflect.Type, fields map[string]reflect.Type) map[string]reflect.Type {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch {
+		case name == "-" || !field.IsExported():
+		case field.Anonymous && name == "":
+			schemaFields(field.Type, fields)
+		case name == "":
+			fields[field.Name] = field.Type
+		default:
+			fields[name] = field.Type
+		}
+	}
+	return fields
+}
+
+// Checks value at path against the Go type it is decoded into, adding a
+// ConfigError for every unknown field and value of the wrong type
+func checkSchema(file string, path string, value any, t reflect.Type, errs *[]error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// Null keeps the value of the lower layer or the default
+	if value == nil {
+		return
+	}
+	fail := func() {
+		*errs = append(*errs, &ConfigError{File: file, Path: path, Message: fmt.Sprintf("expected %s, got %s", schemaType(t.Kind()), valueType(value))})
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			fail()
+			return
+		}
+		fields := schemaFields(t, make(map[string]reflect.Type))
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if field, ok := fields[key]; ok {
+				checkSchema(file, fieldPath(path, key), object[key], field, errs)
+			} else {
+				*errs = append(*errs, &ConfigError{File: file, Path: fieldPath(path, key), Message: "unknown field"})
+			}
+		}
+
+	case reflect.Map:
+		object, ok := value.(map[string]any)
+		if !ok {
+			fail()
+			return
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			checkSchema(file, fieldPath(path, key), object[key], t.Elem(), errs)
+		}
+
+	case reflect.Slice:
+		list, ok := value.([]any)
+		if !ok {
+			fail()
+			return
+		}
+		for i, item := range list {
+			checkSchema(file, fmt.Sprintf("%s[%d]", path, i), item, t.Elem(), errs)
+		}
+
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			fail()
+		}
+
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			fail()
+		}
+
+	case reflect.Int, reflect.Int64:
+		// JSON numbers are read as float64 and YAML integers as int
+		switch n := value.(type) {
+		case int, int64, uint64:
+		case float64:
+			if n != math.Trunc(n) {
+				fail()
+			}
+		default:
+			fail()
+		}
+
+	case reflect.Float64:
+		switch value.(type) {
+		case int, int64, uint64, float64:
+		default:
+			fail()
+		}
+	}
+}
+
+// Name of a Go kind as written in the config files
+func schemaType(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	case reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice:
+		return "list"
+	}
+	return kind.String()
+}
+
+// Name of the type of a value read from a config file
+func valueType(value any) string {
+	switch v := value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "number"
+	case float64:
+		if v == math.Trunc(v) {
+			return "number"
+		}
+		return fmt.Sprintf("number %g", v)
+	case map[string]any:
+		return "object"
+	case []any:
+		return "list"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// Objects are merged key by key, anything else, lists included, replaces the
+// value of the lower layer
+func mergeLayer(base map[string]any, layer map[string]any) {
+	for key, value := range layer {
+		baseObject, baseIsObject := base[key].(map[string]any)
+		object, isObject := value.(map[string]any)
+		if baseIsObject && isObject {
+			mergeLayer(baseObject, object)
+		} else {
+			base[key] = value
+		}
+	}
+}
+
+// Merges the config files over the defaults, later files taking precedence,
+// e.g. LoadConfig(GlobalConfigPath(), projectConfig). Empty paths are skipped.
+// The result is not validated so that command line flags can be applied
+// first.
+func LoadConfig(paths ...string) (*ExporterConfig, error) {
+	merged := make(map[string]any)
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		layer, err := readLayer(p)
+		if err != nil {
+			return nil, err
+		}
+		mergeLayer(merged, layer)
+	}
+
+	config := DefaultConfig()
+	if err := decodeStrict("", merged, config); err != nil {
+		return nil, err
+	}
+	config.Version = CONFIG_VERSION
+	return config, nil
+}
+
+// Loads a single config file over the defaults, validates it and resolves
+// the credentials
+func LoadConfigFromFile(path string) (*ExporterConfig, error) {
+	config, err := LoadConfig(path)
 	if err != nil {
-		fatalError("failed to read onshape client config", err)
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
+	if err := config.ResolveCredentials(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
 
-	var configJson ExporterConfig
-	err = json.Unmarshal(contents, &configJson)
+// Fills in the API keys and base url from the environment, the user
+// credentials file or the keyring, see onshape.DefaultProviders
+func (c *ExporterConfig) ResolveCredentials() error {
+	credentials, err := onshape.ResolveCredentials(onshape.DefaultProviders(c.OnshapeClient)...)
 	if err != nil {
-		fatalError("failed to parse onshape client config", err)
+		return err
+	}
+	credentials.BaseUrl, err = onshape.ResolveBaseURL(c.OnshapeClient.BaseUrl)
+	if err != nil {
+		return err
+	}
+	credentials.Auth = c.OnshapeClient.Auth
+	c.OnshapeClient = credentials
+
+	// The document can also be given on the command line, base_url then only selects the server
+	c.BaseElement = nil
+	if base := c.OnshapeClient.BaseUrl; base != "" && !onshape.IsServerURL(base) {
+		c.BaseElement, err = onshape.ElementFromURL(base)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ExporterConfig) ModelOptions() mjcf.ModelOptions {
+	return mjcf.ModelOptions{
+		Units:           c.Output.UnitOptions,
+		Physics:         c.Physics,
+		DefaultMaterial: c.Materials.Default,
+		Collision:       c.Collision,
+		Materials:       c.Materials.Table,
+		Parts:           c.Parts,
+		Naming:          c.Naming,
+	}
+}
+
+// Copy that is safe to print, with the secrets replaced by REDACTED
+func (c *ExporterConfig) Redacted() *ExporterConfig {
+	redacted := *c
+	if redacted.OnshapeClient.SecretKey != "" {
+		redacted.OnshapeClient.SecretKey = onshape.REDACTED
+	}
+	if c.OAuth != nil {
+		oauth := *c.OAuth
+		if oauth.ClientSecret != "" {
+			oauth.ClientSecret = onshape.REDACTED
+		}
+		redacted.OAuth = &oauth
+	}
+	return &redacted
+}
+
+var (
+	stlUnits = []string{"meter", "centimeter", "millimeter", "inch", "foot", "yard"}
+	stlModes = []string{"text", "binary"}
+	integrators = []string{"Euler", "RK4", "implicit", "implicitfast"}
+	collisionStrategies = []string{mjcf.COLLISION_MESH, mjcf.COLLISION_NONE}
+	authMethods = []string{onshape.AUTH_BASIC, onshape.AUTH_HMAC}
+	namePlaceholders = []string{"assembly", "part", "instance", "id", "n"}
+)
+
+// Collects every problem instead of stopping at the first
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(path string, format string, args ...any) {
+	v.errs = append(v.errs, &ConfigError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) oneOf(path string, value string, allowed []string, optional bool) {
+	if value == "" && optional {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.fail(path, "unknown value %q, expected one of %s", value, strings.Join(allowed, ", "))
+}
+
+func (v *validator) nonNegative(path string, value float64) {
+	if value < 0 {
+		v.fail(path, "must not be negative, got %g", value)
+	}
+}
+
+// Space separated numbers as in MJCF attributes, nil when empty or invalid
+func (v *validator) numbers(path string, value string, min int, max int) []float64 {
+	if value == "" {
+		return nil
+	}
+	fields := strings.Fields(value)
+	if len(fields) < min || len(fields) > max {
+		if min == max {
+			v.fail(path, "expected %d numbers, got %q", min, value)
+		} else {
+			v.fail(path, "expected %d to %d numbers, got %q", min, max, value)
+		}
+		return nil
+	}
+	numbers := make([]float64, len(fields))
+	for i, f := range fields {
+		n, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			v.fail(path, "%q is not a number", f)
+			return nil
+		}
+		numbers[i] = n
+	}
+	return numbers
+}
+
+func (v *validator) pattern(p string, pattern string) {
+	if pattern == "" {
+		v.fail(p, "must not be empty")
+	} else if _, err := path.Match(pattern, ""); err != nil {
+		v.fail(p, "invalid pattern %q", pattern)
+	}
+}
+
+func (v *validator) template(path string, template string) {
+	rest := template
+	for {
+		start := strings.Index(rest, "{")
+		end := strings.Index(rest, "}")
+		if start < 0 && end < 0 {
+			return
+		}
+		if start < 0 || end < start {
+			v.fail(path, "unbalanced braces in %q", template)
+			return
+		}
+		name := rest[start + 1 : end]
+		known := false
+		for _, p := range namePlaceholders {
+			known = known || name == p
+		}
+		if !known {
+			v.fail(path, "unknown placeholder {%s}, expected one of {%s}", name, strings.Join(namePlaceholders, "}, {"))
+		}
+		rest = rest[end + 1:]
+	}
+}
+
+// Checks every value and returns all problems joined with errors.Join, each
+// a ConfigError with the path of the field
+func (c *ExporterConfig) Validate() error {
+	v := &validator{}
+
+	v.oneOf("onshape_client.auth", c.OnshapeClient.Auth, authMethods, true)
+	if c.OAuth != nil && c.OAuth.ClientId == "" {
+		v.fail("oauth.client_id", "must not be empty")
+	}
+	if c.OAuth != nil && c.OAuth.RedirectURL != "" {
+		if _, err := onshape.ParseRedirectURL(c.OAuth.RedirectURL); err != nil {
+			v.fail("oauth.redirect_url", "must be an http url on a loopback address with a port, got %q", c.OAuth.RedirectURL)
+		}
 	}
 
-	configJson.BaseElement = OnshapeElementFromURL(configJson.OnshapeClient.BaseUrl)
+	v.oneOf("stl_export_options.units", c.StlExportOptions.Units, stlUnits, true)
+	// Meshes in other units would not line up with the bodies
+	if units := c.Output.StlUnits(); c.StlExportOptions.Units != "" && c.StlExportOptions.Units != units {
+		v.fail("stl_export_options.units", "must be %q to match output.length_unit, got %q", units, c.StlExportOptions.Units)
+	}
+	v.oneOf("stl_export_options.mode", c.StlExportOptions.Mode, stlModes, false)
+	if c.StlExportOptions.Concurrency < 0 {
+		v.fail("stl_export_options.concurrency", "must not be negative, got %d", c.StlExportOptions.Concurrency)
+	}
 
-	return &configJson
-}
\ No newline at end of file
+	if c.Output.ModelFile == "" {
+		v.fail("output.model_file", "must not be empty")
+	}
+	if c.Output.MeshDir == "" {
+		v.fail("output.mesh_dir", "must not be empty")
+	}
+	v.oneOf("output.length_unit", c.Output.Length, mjcf.LengthUnits, false)
+	v.oneOf("output.angle_unit", c.Output.Angle, mjcf.AngleUnits, false)
+	v.oneOf("output.up_axis", c.Output.Up, mjcf.UpAxes, false)
+
+	v.oneOf("assembly.standard_content", c.Assembly.StandardContent, assembly.StandardContentPolicies, true)
+	v.oneOf("assembly.hidden", c.Assembly.Hidden, assembly.HiddenPolicies, true)
+
+	if c.Physics.Timestep <= 0 {
+		v.fail("physics.timestep", "must be positive, got %g", c.Physics.Timestep)
+	}
+	v.numbers("physics.gravity", c.Physics.Gravity, 3, 3)
+	v.oneOf("physics.integrator", c.Physics.Integrator, integrators, true)
+
+	for _, n := range v.numbers("materials.default.rgba", c.Materials.Default.Rgba, 4, 4) {
+		if n < 0 || n > 1 {
+			v.fail("materials.default.rgba", "values must be between 0 and 1, got %g", n)
+			break
+		}
+	}
+	v.nonNegative("materials.default.density", c.Materials.Default.Density)
+	v.numbers("materials.default.friction", c.Materials.Default.Friction, 1, 3)
+
+	for i, rule := range c.Materials.Table {
+		prefix := fmt.Sprintf("materials.table[%d].", i)
+		v.pattern(prefix + "match", rule.Match)
+		v.nonNegative(prefix + "density", rule.Density)
+		v.numbers(prefix + "friction", rule.Friction, 1, 3)
+		v.numbers(prefix + "solref", rule.Solref, 2, 2)
+		v.numbers(prefix + "solimp", rule.Solimp, 3, 5)
+	}
+
+	v.oneOf("collision.strategy", c.Collision.Strategy, collisionStrategies, false)
+
+	for i, part := range c.Parts {
+		prefix := fmt.Sprintf("parts[%d].", i)
+		v.pattern(prefix + "match", part.Match)
+		v.oneOf(prefix + "collision", part.Collision, append(collisionStrategies, mjcf.CollisionPrimitives...), true)
+		v.numbers(prefix + "friction", part.Friction, 1, 3)
+		v.nonNegative(prefix + "density", part.Density)
+	}
+
+	v.template("naming.body", c.Naming.Body)
+	v.template("naming.geom", c.Naming.Geom)
+	v.template("naming.mesh", c.Naming.Mesh)
+	// A mesh is shared by every instance of the part
+	if strings.Contains(c.Naming.Mesh, "{instance}") {
+		v.fail("naming.mesh", "{instance} is not available for meshes")
+	}
+
+	return errors.Join(v.errs...)
+}
@@ -0,0 +1,72 @@
+package exporter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"onshape-mcjf-exporter/onshape"
+)
+
+func TestLoadConfigReportsEverySchemaError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "project.yaml")
+	contents := `
+timestpe: 3
+physics: {timestep: fast, gravity: [0, 0]}
+parts:
+  - {match: "Screw*", exlude: true}
+  - {match: "Wheel*", density: heavy}
+stl_export_options: {concurrency: 1.5}
+configuration: {Side: 3}
+`
+	if err := os.WriteFile(file, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadConfig(file)
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("got %v, want ErrInvalidConfig", err)
+	}
+	lines := []string{
+		file + ": configuration.Side: expected string, got number",
+		file + ": parts[0].exlude: unknown field",
+		file + ": parts[1].density: expected number, got string",
+		file + ": physics.gravity: expected string, got list",
+		file + ": physics.timestep: expected number, got string",
+		file + ": stl_export_options.concurrency: expected integer, got number 1.5",
+		file + ": timestpe: unknown field",
+	}
+	if got, want := err.Error(), strings.Join(lines, "\n"); got != want {
+		t.Errorf("got errors\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestValidateRejectsRedirectURLs(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"http://localhost:8910/callback", true},
+		{"http://127.0.0.1:8910", true},
+		{"http://[::1]:8910/callback", true},
+		{"https://localhost:8910/callback", false},
+		{"http://192.168.1.20:8910/callback", false},
+		{"http://example.com:8910/callback", false},
+		{"http://localhost/callback", false},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			config := DefaultConfig()
+			config.OAuth = &onshape.OAuthConfig{ClientId: "client", RedirectURL: test.url}
+			err := config.Validate()
+			if test.valid && err != nil {
+				t.Errorf("got %v, want no error", err)
+			}
+			if !test.valid && (err == nil || !strings.HasPrefix(err.Error(), "oauth.redirect_url: ")) {
+				t.Errorf("got %v, want an oauth.redirect_url error", err)
+			}
+		})
+	}
+}
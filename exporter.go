@@ -0,0 +1,298 @@
+// Package exporter converts Onshape assemblies into MJCF models.
+//
+// Export runs the whole pipeline. The onshape, assembly, mesh and mjcf
+// packages expose the individual stages for callers that need more control.
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"onshape-mcjf-exporter/assembly"
+	"onshape-mcjf-exporter/mesh"
+	"onshape-mcjf-exporter/mjcf"
+	"onshape-mcjf-exporter/onshape"
+)
+
+const (
+	MESH_DIR_NAME   = "meshes"
+	MODEL_FILE_NAME = "model.xml"
+)
+
+type Options struct {
+	Credentials      onshape.Credentials
+	// OAuth user to act as instead of the API keys, see onshape.NewTokenSource
+	TokenSource      oauth2.TokenSource
+	StlExportOptions mesh.ExportOptions
+	CacheDir         string
+	Offline          bool
+	// Directory receiving the model file and its meshes
+	OutputDir string
+	Transport http.RoundTripper
+	Debug     bool
+	// Immutable reference to export from, see PinElement
+	Pin string
+	// Configuration parameter values by name or id, overriding the url
+	Configuration map[string]string
+	// Values taking precedence over Configuration, e.g. from the command line
+	ConfigurationOverrides map[string]string
+	// How the assembly definition is read, e.g. the standard content policy
+	Assembly assembly.LoadOptions
+	// Physics, materials and overrides of the model, mjcf.DefaultModelOptions when nil
+	Model *mjcf.ModelOptions
+	// Names inside OutputDir, MODEL_FILE_NAME and MESH_DIR_NAME when empty
+	ModelFile string
+	MeshDir   string
+	// Record the export time in the header, leaving it out keeps exports of
+	// an unchanged assembly byte-identical
+	Timestamp bool
+}
+
+// Options equivalent to an exporter config file
+func (c *ExporterConfig) Options() Options {
+	model := c.ModelOptions()
+	stl := c.StlExportOptions
+	if stl.Units == "" {
+		stl.Units = c.Output.StlUnits()
+	}
+	return Options{
+		Credentials:      c.OnshapeClient,
+		StlExportOptions: stl,
+		CacheDir:         c.CacheDir,
+		Configuration:    c.Configuration,
+		Assembly:         c.Assembly,
+		Model:            &model,
+		ModelFile:        c.Output.ModelFile,
+		MeshDir:          c.Output.MeshDir,
+		Timestamp:        c.Output.Timestamp,
+	}
+}
+
+type Result struct {
+	Model      assembly.ModelData
+	Provenance *mjcf.Provenance
+	ModelPath  string
+	MeshDir    string
+	// Parts written to MeshDir, without those excluded by overrides
+	MeshParts []assembly.PartInfo
+}
+
+// Connects to the server the element url points at
+func NewClient(ctx context.Context, element *onshape.Element, opts Options) *onshape.Client {
+	return onshape.NewClient(ctx, onshape.ClientOptions{
+		Credentials: opts.Credentials,
+		TokenSource: opts.TokenSource,
+		ServerURL:   element.ServerURL,
+		CacheDir:    opts.CacheDir,
+		Offline:     opts.Offline,
+		Transport:   opts.Transport,
+		Debug:       opts.Debug,
+	})
+}
+
+// Fetches the assembly at url without writing anything
+func Load(ctx context.Context, url string, opts Options) (*onshape.Client, assembly.ModelData, error) {
+	element, err := onshape.ElementFromURL(url)
+	if err != nil {
+		return nil, assembly.ModelData{}, err
+	}
+	return LoadElement(ctx, element, opts)
+}
+
+func LoadElement(ctx context.Context, element *onshape.Element, opts Options) (*onshape.Client, assembly.ModelData, error) {
+	client, model, _, err := loadElement(ctx, element, opts)
+	return client, model, err
+}
+
+func loadElement(ctx context.Context, element *onshape.Element, opts Options) (*onshape.Client, assembly.ModelData, *mjcf.Provenance, error) {
+	client := NewClient(ctx, element, opts)
+	pinned, provenance, err := PinElement(client, element, opts.Pin)
+	if err != nil {
+		return nil, assembly.ModelData{}, nil, err
+	}
+	pinned.ElementPath, err = client.Configure(pinned.ElementPath, opts.Configuration, opts.ConfigurationOverrides)
+	if err != nil {
+		return nil, assembly.ModelData{}, nil, err
+	}
+	provenance.Configuration = pinned.Configuration
+	model, err := assembly.NewModelData(client, pinned.ElementPath, opts.Assembly)
+	if err != nil {
+		return nil, assembly.ModelData{}, nil, err
+	}
+	return client, model, provenance, nil
+}
+
+// Loads the assembly at url and writes it with its meshes to opts.OutputDir
+func Export(ctx context.Context, url string, opts Options) (*Result, error) {
+	element, err := onshape.ElementFromURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return ExportElement(ctx, element, opts)
+}
+
+func ExportElement(ctx context.Context, element *onshape.Element, opts Options) (*Result, error) {
+	client, model, provenance, err := loadElement(ctx, element, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	meshDirName := opts.MeshDir
+	if meshDirName == "" {
+		meshDirName = MESH_DIR_NAME
+	}
+	modelFileName := opts.ModelFile
+	if modelFileName == "" {
+		modelFileName = MODEL_FILE_NAME
+	}
+
+	meshDir := filepath.Join(opts.OutputDir, meshDirName)
+	modelPath := filepath.Join(opts.OutputDir, modelFileName)
+	// MuJoCo resolves meshdir relative to the model file
+	relMeshDir, err := filepath.Rel(filepath.Dir(modelPath), meshDir)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Timestamp {
+		provenance.ExportedAt = time.Now()
+	}
+	modelWriter := makeModel(model, provenance, filepath.ToSlash(relMeshDir), opts)
+	if err := saveMeshes(client, modelWriter, meshDir, opts); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(modelPath), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(modelPath, []byte(modelWriter.ModelToString()), 0644); err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Model:      model,
+		Provenance: provenance,
+		ModelPath:  modelPath,
+		MeshDir:    meshDir,
+		MeshParts:  modelWriter.MeshParts(),
+	}, nil
+}
+
+// Writer holding the model of the loaded assembly, made with opts
+func makeModel(model assembly.ModelData, provenance *mjcf.Provenance, meshDir string, opts Options) *mjcf.ModelWriter {
+	modelWriter := mjcf.NewModelWriter(model)
+	modelWriter.MeshDir = meshDir
+	modelWriter.Provenance = provenance
+	if opts.Model != nil {
+		modelWriter.Options = *opts.Model
+	}
+	modelWriter.MakeModel()
+	return modelWriter
+}
+
+// Downloads the meshes referenced by the model to dir, in the model units.
+// Parts excluded by overrides or left without a geom are not downloaded.
+func saveMeshes(client *onshape.Client, modelWriter *mjcf.ModelWriter, dir string, opts Options) error {
+	stl := opts.StlExportOptions
+	if stl.Units == "" {
+		stl.Units = modelWriter.Options.Units.StlUnits()
+	}
+	return mesh.SaveStlsToDir(client, mesh.NewCache(opts.CacheDir), modelWriter.MeshParts(), modelWriter.MeshName, dir, stl)
+}
+
+// Writes only the meshes of the model ExportElement would write, under the
+// same file names, to dir
+func ExportMeshes(ctx context.Context, element *onshape.Element, dir string, opts Options) ([]assembly.PartInfo, error) {
+	client, model, provenance, err := loadElement(ctx, element, opts)
+	if err != nil {
+		return nil, err
+	}
+	modelWriter := makeModel(model, provenance, dir, opts)
+	if err := saveMeshes(client, modelWriter, dir, opts); err != nil {
+		return nil, err
+	}
+	return modelWriter.MeshParts(), nil
+}
+
+// Exports one model per option of the list parameter into subdirectories of
+// opts.OutputDir named after the options
+func ExportConfigurations(ctx context.Context, element *onshape.Element, parameter string, opts Options) ([]*Result, error) {
+	client := NewClient(ctx, element, opts)
+	parameters, err := client.GetConfigurationParameters(element.ElementPath)
+	if err != nil {
+		return nil, err
+	}
+	list, err := onshape.FindConfigurationParameter(parameters, parameter)
+	if err != nil {
+		return nil, err
+	}
+	if len(list.OptionIds) == 0 {
+		return nil, fmt.Errorf("%w: %s is not a list", onshape.ErrInvalidConfiguration, list.Name)
+	}
+
+	dirs := optionDirs(list.OptionNames)
+	results := make([]*Result, 0, len(list.OptionIds))
+	for i, option := range list.OptionIds {
+		variant := opts
+		variant.OutputDir = filepath.Join(opts.OutputDir, dirs[i])
+		// The option wins over any value given for the list
+		variant.Configuration = withoutParameter(opts.Configuration, list)
+		variant.ConfigurationOverrides = withoutParameter(opts.ConfigurationOverrides, list)
+		variant.ConfigurationOverrides[list.Id] = option
+
+		result, err := ExportElement(ctx, element, variant)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s=%s: %w", list.Name, list.OptionNames[i], err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Copy of values without the parameter, given by name or id
+func withoutParameter(values map[string]string, parameter *onshape.ConfigurationParameter) map[string]string {
+	rest := make(map[string]string, len(values))
+	for name, value := range values {
+		if name != parameter.Id && name != parameter.Name {
+			rest[name] = value
+		}
+	}
+	return rest
+}
+
+// Directory names of the options, with "_2", "_3"... appended to options
+// whose file names would collide, ignoring case for case-insensitive file
+// systems
+func optionDirs(options []string) []string {
+	dirs := make([]string, len(options))
+	used := make(map[string]bool, len(options))
+	for i, option := range options {
+		base := fileName(option)
+		dir := base
+		for n := 2; used[strings.ToLower(dir)]; n++ {
+			dir = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[strings.ToLower(dir)] = true
+		dirs[i] = dir
+	}
+	return dirs
+}
+
+// Replaces characters that are unsafe in file names
+func fileName(name string) string {
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	safe := []rune(name)
+	for i, r := range safe {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-' || r == '.') {
+			safe[i] = '_'
+		}
+	}
+	return string(safe)
+}
@@ -0,0 +1,712 @@
+package exporter_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	onshapeapi "github.com/onshape-public/go-client/onshape"
+	exporter "onshape-mcjf-exporter"
+	"onshape-mcjf-exporter/assembly"
+	"onshape-mcjf-exporter/mjcf"
+	"onshape-mcjf-exporter/onshape"
+	"onshape-mcjf-exporter/onshapetest"
+)
+
+const (
+	TEST_DOCUMENT     = "d1"
+	TEST_WORKSPACE    = "w1"
+	TEST_ELEMENT      = "e1"
+	TEST_MICROVERSION = "mv1"
+	TEST_PART_STUDIO  = "ps1"
+	TEST_STL          = "solid plate\nendsolid plate\n"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func translation(x float64, y float64, z float64) []float64 {
+	return []float64{
+		1, 0, 0, x,
+		0, 1, 0, y,
+		0, 0, 1, z,
+		0, 0, 0, 1,
+	}
+}
+
+// Assembly of a plate and a leg sub-assembly holding a wheel, all instances
+// of one part
+func testAssembly() onshapeapi.BTAssemblyDefinitionInfo {
+	did, mv, ps := TEST_DOCUMENT, TEST_MICROVERSION, TEST_PART_STUDIO
+	partType := onshapeapi.BTAssemblyInstanceTypePart
+	part := func(id string, name string) onshapeapi.BTAssemblyInstanceInfo {
+		return onshapeapi.BTAssemblyInstanceInfo{Id: ptr(id), Name: ptr(name), Type: &partType, DocumentId: &did, DocumentMicroversion: &mv, ElementId: &ps, PartId: ptr("JHD")}
+	}
+	return onshapeapi.BTAssemblyDefinitionInfo{
+		Parts: []onshapeapi.BTAssemblyPartInfo{{DocumentId: &did, DocumentMicroversion: &mv, ElementId: &ps, PartId: ptr("JHD"), IsStandardContent: ptr(false)}},
+		SubAssemblies: []onshapeapi.BTSubAssemblyInfo{{DocumentId: &did, DocumentMicroversion: &mv, ElementId: ptr("leg"),
+			Instances: []onshapeapi.BTAssemblyInstanceInfo{part("i2", "Wheel <1>")}}},
+		RootAssembly: &onshapeapi.BTRootAssemblyInfo{
+			Instances: []onshapeapi.BTAssemblyInstanceInfo{
+				part("i1", "Plate <1>"),
+				{Id: ptr("a1"), Name: ptr("Leg <1>"), Type: ptr(onshapeapi.BTAssemblyInstanceTypeAssembly), DocumentId: &did, DocumentMicroversion: &mv, ElementId: ptr("leg")},
+			},
+			Occurrences: []onshapeapi.BTAssemblyOccurrenceInfo{
+				{Path: []string{"i1"}, Transform: translation(0, 0, 0)},
+				{Path: []string{"a1"}, Transform: translation(0.1, 0, 0)},
+				{Path: []string{"a1", "i2"}, Transform: translation(0.3, 0, 0)},
+			},
+		},
+	}
+}
+
+// Fake server with testAssembly in a workspace, and the url of the workspace
+func newTestServer(t *testing.T) (*onshapetest.Server, string) {
+	t.Helper()
+	s := onshapetest.NewServer()
+	t.Cleanup(s.Close)
+
+	did, mv, ps := TEST_DOCUMENT, TEST_MICROVERSION, TEST_PART_STUDIO
+	s.AddDocument(did, "Robot")
+	s.AddElement(did, "w", TEST_WORKSPACE, TEST_ELEMENT, "Top", onshapeapi.GBTElementTypeAssembly)
+	s.AddElement(did, "m", mv, "leg", "Leg", onshapeapi.GBTElementTypeAssembly)
+	s.AddAssembly(did, "w", TEST_WORKSPACE, TEST_ELEMENT, testAssembly())
+	s.SetMicroversion(did, "w", TEST_WORKSPACE, mv)
+	s.AddParts(did, "m", mv, ps, []onshapeapi.BTPartMetadataInfo{{
+		PartId:     ptr("JHD"),
+		Name:       ptr("Plate"),
+		Appearance: &onshapeapi.BTPartAppearanceInfo{Color: &onshapeapi.BTColorInfo{Red: ptr(int32(10)), Green: ptr(int32(20)), Blue: ptr(int32(30))}, Opacity: ptr(int32(255))},
+	}})
+	s.AddMesh(did, "m", mv, ps, "JHD", []byte(TEST_STL))
+	return s, s.ElementURL(did, "w", TEST_WORKSPACE, TEST_ELEMENT)
+}
+
+// Part of TEST_PART_STUDIO, with the metadata the parts endpoint returns
+type testPart struct {
+	id       string
+	standard bool
+	metadata onshapeapi.BTPartMetadataInfo
+}
+
+// Metadata of a gray part
+func partMetadata(id string, name string) onshapeapi.BTPartMetadataInfo {
+	gray := ptr(int32(128))
+	return onshapeapi.BTPartMetadataInfo{
+		PartId:     ptr(id),
+		Name:       ptr(name),
+		Appearance: &onshapeapi.BTPartAppearanceInfo{Color: &onshapeapi.BTColorInfo{Red: gray, Green: gray, Blue: gray}, Opacity: ptr(int32(255))},
+	}
+}
+
+// Top level instance of a test part, instance k placed at (0, 0, k)
+type testInstance struct {
+	id         string
+	name       string
+	part       string
+	hidden     bool
+	suppressed bool
+}
+
+// Fake server with an assembly of the instances directly under the root, and
+// the url of its workspace
+func newFlatServer(t *testing.T, parts []testPart, instances []testInstance) (*onshapetest.Server, string) {
+	t.Helper()
+	s := onshapetest.NewServer()
+	t.Cleanup(s.Close)
+
+	did, mv, ps := TEST_DOCUMENT, TEST_MICROVERSION, TEST_PART_STUDIO
+	def := onshapeapi.BTAssemblyDefinitionInfo{RootAssembly: &onshapeapi.BTRootAssemblyInfo{}}
+	metadata := make([]onshapeapi.BTPartMetadataInfo, 0, len(parts))
+	standard := make(map[string]bool)
+	for _, part := range parts {
+		def.Parts = append(def.Parts, onshapeapi.BTAssemblyPartInfo{DocumentId: &did, DocumentMicroversion: &mv, ElementId: &ps, PartId: ptr(part.id), IsStandardContent: ptr(part.standard)})
+		metadata = append(metadata, part.metadata)
+		standard[part.id] = part.standard
+		s.AddMesh(did, "m", mv, ps, part.id, []byte("solid "+part.id))
+	}
+	partType := onshapeapi.BTAssemblyInstanceTypePart
+	for k, instance := range instances {
+		def.RootAssembly.Instances = append(def.RootAssembly.Instances, onshapeapi.BTAssemblyInstanceInfo{
+			Id: ptr(instance.id), Name: ptr(instance.name), Type: &partType, DocumentId: &did, DocumentMicroversion: &mv, ElementId: &ps,
+			PartId: ptr(instance.part), IsStandardContent: ptr(standard[instance.part]), Suppressed: ptr(instance.suppressed),
+		})
+		def.RootAssembly.Occurrences = append(def.RootAssembly.Occurrences, onshapeapi.BTAssemblyOccurrenceInfo{Path: []string{instance.id}, Transform: translation(0, 0, float64(k)), Hidden: ptr(instance.hidden)})
+	}
+
+	s.AddDocument(did, "Flat")
+	s.AddElement(did, "w", TEST_WORKSPACE, TEST_ELEMENT, "Top", onshapeapi.GBTElementTypeAssembly)
+	s.AddAssembly(did, "w", TEST_WORKSPACE, TEST_ELEMENT, def)
+	s.SetMicroversion(did, "w", TEST_WORKSPACE, mv)
+	s.AddParts(did, "m", mv, ps, metadata)
+	return s, s.ElementURL(did, "w", TEST_WORKSPACE, TEST_ELEMENT)
+}
+
+// Names of the files in dir, sorted
+func fileNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func testOptions(t *testing.T) exporter.Options {
+	return exporter.Options{
+		Credentials: onshape.Credentials{AccessKey: "access", SecretKey: "secret"},
+		CacheDir:    t.TempDir(),
+		OutputDir:   t.TempDir(),
+	}
+}
+
+// Bodies of the model by name
+func modelBodies(t *testing.T, path string) map[string]*mjcf.Node {
+	t.Helper()
+	bodies := make(map[string]*mjcf.Node)
+	mustParse(t, path).Walk(func(_ string, node *mjcf.Node) {
+		if name, ok := node.Attr("name"); ok && node.Tag() == "body" {
+			bodies[name] = node
+		}
+	})
+	return bodies
+}
+
+func TestExportAgainstFakeServer(t *testing.T) {
+	s, url := newTestServer(t)
+	opts := testOptions(t)
+
+	result, err := exporter.Export(context.Background(), url, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Not pinned, but still traceable to the CAD state
+	if result.Provenance.Microversion != TEST_MICROVERSION {
+		t.Errorf("provenance microversion %q, want %q", result.Provenance.Microversion, TEST_MICROVERSION)
+	}
+
+	bodies := modelBodies(t, result.ModelPath)
+	for name, pos := range map[string]string{"Plate_1": "0 0 0", "Leg_1": "0.1 0 0", "Wheel_1": "0.2 0 0"} {
+		body, ok := bodies[name]
+		if !ok {
+			t.Errorf("no body %s in %v", name, bodies)
+			continue
+		}
+		if got, _ := body.Attr("pos"); got != pos {
+			t.Errorf("body %s at %q, want %q", name, got, pos)
+		}
+	}
+	if errs := mjcf.Validate(mustParse(t, result.ModelPath), filepath.Dir(result.ModelPath)); len(errs) != 0 {
+		t.Errorf("invalid model: %v", errs)
+	}
+
+	// Every instance shares the mesh of the one part
+	if len(result.MeshParts) != 1 {
+		t.Fatalf("%d mesh parts, want 1", len(result.MeshParts))
+	}
+	stl, err := os.ReadFile(filepath.Join(result.MeshDir, "Plate.stl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stl) != TEST_STL {
+		t.Errorf("mesh %q, want %q", stl, TEST_STL)
+	}
+	if n := s.RequestCount("/parts/d/" + TEST_DOCUMENT + "/m/" + TEST_MICROVERSION + "/e/" + TEST_PART_STUDIO + "/partid/JHD/stl"); n != 1 {
+		t.Errorf("mesh exported %d times, want 1", n)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	s, url := newTestServer(t)
+	fixtures := t.TempDir()
+
+	recorded := testOptions(t)
+	recorded.Transport = onshape.NewFixtureTransport(fixtures, onshape.FixtureRecord, nil)
+	first, err := exporter.Export(context.Background(), url, recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing can reach the server anymore, and the replay starts from an
+	// empty cache
+	s.Close()
+	replayed := testOptions(t)
+	replayed.Transport = onshape.NewFixtureTransport(fixtures, onshape.FixtureReplay, nil)
+	second, err := exporter.Export(context.Background(), url, replayed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changes := mjcf.Diff(mustParse(t, first.ModelPath), mustParse(t, second.ModelPath)); len(changes) != 0 {
+		t.Errorf("replayed model differs: %v", changes)
+	}
+	stl, err := os.ReadFile(filepath.Join(second.MeshDir, "Plate.stl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stl) != TEST_STL {
+		t.Errorf("replayed mesh %q, want %q", stl, TEST_STL)
+	}
+}
+
+func mustParse(t *testing.T, path string) *mjcf.Node {
+	t.Helper()
+	root, err := mjcf.ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestRepeatedExportsAreByteIdentical(t *testing.T) {
+	_, url := newTestServer(t)
+
+	var models []string
+	for i := 0; i < 2; i++ {
+		result, err := exporter.Export(context.Background(), url, testOptions(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		model, err := os.ReadFile(result.ModelPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		models = append(models, string(model))
+	}
+	if models[0] != models[1] {
+		t.Errorf("exports differ:\n%s\n%s", models[0], models[1])
+	}
+}
+
+func TestRepeatedInstanceNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		legs   int
+		feet   int
+		plates int
+		bodies []string
+	}{
+		{"sub-assembly instanced three times", 3, 1, 0, []string{"Leg_1", "Foot_1_Leg_1", "Leg_2", "Foot_1_Leg_2", "Leg_3", "Foot_1_Leg_3"}},
+		{"part instanced four times", 0, 0, 4, []string{"Plate_1", "Plate_2", "Plate_3", "Plate_4"}},
+		{"patterned part in a repeated sub-assembly", 2, 3, 1, []string{"Leg_1", "Foot_1_Leg_1", "Foot_2_Leg_1", "Foot_3_Leg_1", "Leg_2", "Foot_1_Leg_2", "Foot_2_Leg_2", "Foot_3_Leg_2", "Plate_1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := onshapetest.NewServer()
+			t.Cleanup(s.Close)
+			s.AddRepeatedAssembly(TEST_DOCUMENT, "w", TEST_WORKSPACE, TEST_ELEMENT, test.legs, test.feet, test.plates)
+			s.SetMicroversion(TEST_DOCUMENT, "w", TEST_WORKSPACE, TEST_MICROVERSION)
+
+			result, err := exporter.Export(context.Background(), s.ElementURL(TEST_DOCUMENT, "w", TEST_WORKSPACE, TEST_ELEMENT), testOptions(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var bodies, meshes []string
+			mustParse(t, result.ModelPath).Walk(func(_ string, node *mjcf.Node) {
+				name, _ := node.Attr("name")
+				switch node.Tag() {
+				case "body":
+					bodies = append(bodies, name)
+				case "mesh":
+					meshes = append(meshes, name)
+				}
+			})
+			if strings.Join(bodies, " ") != strings.Join(test.bodies, " ") {
+				t.Errorf("bodies %v, want %v", bodies, test.bodies)
+			}
+			// Every instance shares the mesh of the one part
+			if len(meshes) != 1 || len(result.MeshParts) != 1 {
+				t.Errorf("meshes %v of parts %v, want one", meshes, result.MeshParts)
+			}
+		})
+	}
+}
+
+func TestExportAndMeshesWriteTheSameFiles(t *testing.T) {
+	_, url := newFlatServer(t,
+		[]testPart{{id: "JHD", metadata: partMetadata("JHD", "Bracket")}, {id: "JKD", metadata: partMetadata("JKD", "Bracket")}},
+		[]testInstance{{id: "i1", name: "Bracket <1>", part: "JHD"}, {id: "i2", name: "Bracket <2>", part: "JKD"}},
+	)
+	opts := testOptions(t)
+	model := mjcf.DefaultModelOptions()
+	model.Parts = []mjcf.PartOverride{{Match: "Bracket <2>", Exclude: true}}
+	opts.Model = &model
+
+	result, err := exporter.Export(context.Background(), url, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	element, err := onshape.ElementFromURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meshDir := t.TempDir()
+	parts, err := exporter.ExportMeshes(context.Background(), element, meshDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The excluded bracket neither gets a file nor renames the other one
+	exported, downloaded := fileNames(t, result.MeshDir), fileNames(t, meshDir)
+	if strings.Join(exported, " ") != "Bracket.stl" || strings.Join(downloaded, " ") != strings.Join(exported, " ") {
+		t.Errorf("export wrote %v and meshes wrote %v, want [Bracket.stl] from both", exported, downloaded)
+	}
+	if len(parts) != 1 || parts[0].Id != "JHD" {
+		t.Errorf("meshes of parts %v, want JHD only", parts)
+	}
+}
+
+func TestExportConfigurations(t *testing.T) {
+	s, url := newFlatServer(t, []testPart{{id: "JHD", metadata: partMetadata("JHD", "Plate")}}, []testInstance{{id: "i1", name: "Plate <1>", part: "JHD"}})
+	did, mv, ps := TEST_DOCUMENT, TEST_MICROVERSION, TEST_PART_STUDIO
+	options, names := []string{"opt_a", "opt_b", "opt_c", "opt_d"}, []string{"Left/Front", "Left Front", "Right", "right"}
+	s.AddConfigurationList(did, "w", TEST_WORKSPACE, TEST_ELEMENT, "List_side", "Side", options, names)
+	// Each option has its own part, named after the option
+	for _, option := range options {
+		partConfiguration := "Side=" + option
+		def := onshapeapi.BTAssemblyDefinitionInfo{
+			Parts: []onshapeapi.BTAssemblyPartInfo{{DocumentId: &did, DocumentMicroversion: &mv, ElementId: &ps, PartId: ptr("JHD"), Configuration: &partConfiguration}},
+			RootAssembly: &onshapeapi.BTRootAssemblyInfo{
+				Instances:   []onshapeapi.BTAssemblyInstanceInfo{{Id: ptr("i1"), Name: ptr("Plate <1>"), Type: ptr(onshapeapi.BTAssemblyInstanceTypePart), DocumentId: &did, DocumentMicroversion: &mv, ElementId: &ps, PartId: ptr("JHD"), Configuration: &partConfiguration}},
+				Occurrences: []onshapeapi.BTAssemblyOccurrenceInfo{{Path: []string{"i1"}, Transform: translation(0, 0, 0)}},
+			},
+		}
+		s.AddConfiguredAssembly(did, "w", TEST_WORKSPACE, TEST_ELEMENT, "List_side="+option, def)
+		s.AddConfiguredParts(did, "m", mv, ps, partConfiguration, []onshapeapi.BTPartMetadataInfo{partMetadata("JHD", "Plate "+option)})
+	}
+	element, err := onshape.ElementFromURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := testOptions(t)
+	// Values given for the list do not apply to the exports of its options
+	opts.Configuration = map[string]string{"Side": "Right"}
+	opts.ConfigurationOverrides = map[string]string{"List_side": "Right"}
+
+	results, err := exporter.ExportConfigurations(context.Background(), element, "Side", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(options) {
+		t.Fatalf("%d results, want %d", len(results), len(options))
+	}
+	// Option names that make the same file name, even if only on a case
+	// insensitive file system, get their own directories
+	dirs := []string{"Left_Front", "Left_Front_2", "Right", "right_2"}
+	for i, result := range results {
+		if want := filepath.Join(opts.OutputDir, dirs[i], exporter.MODEL_FILE_NAME); result.ModelPath != want {
+			t.Errorf("option %s written to %s, want %s", names[i], result.ModelPath, want)
+		}
+		if want := "List_side=" + options[i]; result.Provenance.Configuration != want {
+			t.Errorf("option %s exported in configuration %q, want %q", names[i], result.Provenance.Configuration, want)
+		}
+		if len(result.MeshParts) != 1 || result.MeshParts[0].Name != "Plate "+options[i] {
+			t.Errorf("option %s has parts %v, want Plate %s", names[i], result.MeshParts, options[i])
+		}
+	}
+	if got := fileNames(t, opts.OutputDir); strings.Join(got, " ") != "Left_Front Left_Front_2 Right right_2" {
+		t.Errorf("output directories %v", got)
+	}
+}
+
+// Value of a line of the provenance comment of the model, e.g. "Version"
+func headerValue(t *testing.T, path string, label string) string {
+	t.Helper()
+	model, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _, _ := strings.Cut(string(model), "-->")
+	for _, line := range strings.Split(header, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), label+":"); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+func TestPin(t *testing.T) {
+	const (
+		VERSION              = "v1"
+		VERSION_NAME         = "Release 1"
+		VERSION_MICROVERSION = "mv0"
+		// Current microversion of the workspace, ahead of the version
+		CURRENT_MICROVERSION = "mv2"
+	)
+	tests := []struct {
+		name string
+		// Reference of the url, w or m
+		wvm string
+		pin string
+		// Reference the assembly is read from
+		read         string
+		version      string
+		versionName  string
+		microversion string
+	}{
+		{"version name", "w", VERSION_NAME, "/v/" + VERSION, VERSION, VERSION_NAME, VERSION_MICROVERSION},
+		{"version id", "w", VERSION, "/v/" + VERSION, VERSION, VERSION_NAME, VERSION_MICROVERSION},
+		{"current microversion", "w", exporter.PIN_CURRENT, "/m/" + CURRENT_MICROVERSION, "", "", CURRENT_MICROVERSION},
+		{"microversion url", "m", exporter.PIN_CURRENT, "/m/" + CURRENT_MICROVERSION, "", "", CURRENT_MICROVERSION},
+		{"not pinned", "w", "", "/w/" + TEST_WORKSPACE, "", "", CURRENT_MICROVERSION},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, url := newTestServer(t)
+			s.SetMicroversion(TEST_DOCUMENT, "w", TEST_WORKSPACE, CURRENT_MICROVERSION)
+			s.AddVersion(TEST_DOCUMENT, VERSION, VERSION_NAME, VERSION_MICROVERSION)
+			s.AddAssembly(TEST_DOCUMENT, "v", VERSION, TEST_ELEMENT, testAssembly())
+			s.AddAssembly(TEST_DOCUMENT, "m", CURRENT_MICROVERSION, TEST_ELEMENT, testAssembly())
+			if test.wvm == "m" {
+				url = s.ElementURL(TEST_DOCUMENT, "m", CURRENT_MICROVERSION, TEST_ELEMENT)
+			}
+			opts := testOptions(t)
+			opts.Pin = test.pin
+
+			result, err := exporter.Export(context.Background(), url, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := s.RequestCount("/assemblies/d/" + TEST_DOCUMENT + test.read + "/"); n != 1 {
+				t.Errorf("assembly read %d times from %s, want once", n, test.read)
+			}
+			for label, want := range map[string]string{"Version": test.version, "Version name": test.versionName, "Microversion": test.microversion, "Source": url} {
+				if got := headerValue(t, result.ModelPath, label); got != want {
+					t.Errorf("%s %q in the header, want %q", label, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPinUnknownVersion(t *testing.T) {
+	s, url := newTestServer(t)
+	s.AddVersion(TEST_DOCUMENT, "v1", "Release 1", TEST_MICROVERSION)
+	opts := testOptions(t)
+	opts.Pin = "Release 2"
+	if _, err := exporter.Export(context.Background(), url, opts); !errors.Is(err, onshape.ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+// Checks that the body has exactly one geom, with exactly the attributes want
+func checkGeom(t *testing.T, body *mjcf.Node, want map[string]string) {
+	t.Helper()
+	name, _ := body.Attr("name")
+	var geoms []mjcf.Node
+	for _, child := range body.Children {
+		if child.Tag() == "geom" {
+			geoms = append(geoms, child)
+		}
+	}
+	if len(geoms) != 1 {
+		t.Errorf("body %s has %d geoms, want 1", name, len(geoms))
+		return
+	}
+	got := make(map[string]string, len(geoms[0].Attrs))
+	for _, attr := range geoms[0].Attrs {
+		got[attr.Name.Local] = attr.Value
+	}
+	for attr, value := range want {
+		if got[attr] != value {
+			t.Errorf("geom of %s has %s=%q, want %q", name, attr, got[attr], value)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("geom of %s is %v, want %v", name, got, want)
+	}
+}
+
+func TestStandardContentPolicies(t *testing.T) {
+	tests := []struct {
+		policy string
+		// Attributes of the screw geom, nil for no screw body
+		screw  map[string]string
+		meshes []string
+	}{
+		{assembly.STANDARD_CONTENT_SKIP, nil, []string{"Plate.stl"}},
+		{assembly.STANDARD_CONTENT_VISUAL, map[string]string{"name": "Screw_1", "type": "mesh", "mesh": "Screw", "material": "appearance_808080ff", "contype": "0", "conaffinity": "0", "density": "0"}, []string{"Plate.stl", "Screw.stl"}},
+		{assembly.STANDARD_CONTENT_MERGE_MASS, map[string]string{"name": "Screw_1", "type": "sphere", "size": "0.001", "pos": "0.001 0.002 0.003", "mass": "0.05", "contype": "0", "conaffinity": "0", "group": "4", "rgba": "0 0 0 0"}, []string{"Plate.stl"}},
+	}
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			// A fastener from the standard content library, which used to
+			// crash the exporter
+			s, url := newFlatServer(t,
+				[]testPart{{id: "JHD", metadata: partMetadata("JHD", "Plate")}, {id: "SCR", standard: true, metadata: partMetadata("SCR", "Screw")}},
+				[]testInstance{{id: "i1", name: "Plate <1>", part: "JHD"}, {id: "i2", name: "Screw <1>", part: "SCR"}},
+			)
+			s.AddMassProperties(TEST_DOCUMENT, "m", TEST_MICROVERSION, TEST_PART_STUDIO, "SCR", 0.05, [3]float64{0.001, 0.002, 0.003})
+			opts := testOptions(t)
+			opts.Assembly = assembly.LoadOptions{StandardContent: test.policy}
+
+			result, err := exporter.Export(context.Background(), url, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bodies := modelBodies(t, result.ModelPath)
+			if _, ok := bodies["Plate_1"]; !ok {
+				t.Errorf("no plate body in %v", bodies)
+			}
+			screw, ok := bodies["Screw_1"]
+			switch {
+			case test.screw == nil && ok:
+				t.Errorf("skipped screw has a body")
+			case test.screw != nil && !ok:
+				t.Errorf("no screw body in %v", bodies)
+			case test.screw != nil:
+				checkGeom(t, screw, test.screw)
+			}
+			if got := fileNames(t, result.MeshDir); strings.Join(got, " ") != strings.Join(test.meshes, " ") {
+				t.Errorf("meshes %v, want %v", got, test.meshes)
+			}
+			// Mass properties are only fetched for merging
+			massRequests := s.RequestCount("/parts/d/" + TEST_DOCUMENT + "/m/" + TEST_MICROVERSION + "/e/" + TEST_PART_STUDIO + "/partid/SCR/massproperties")
+			if want := map[bool]int{true: 1}[test.policy == assembly.STANDARD_CONTENT_MERGE_MASS]; massRequests != want {
+				t.Errorf("%d mass properties requests, want %d", massRequests, want)
+			}
+		})
+	}
+}
+
+func TestSuppressedAndHiddenInstances(t *testing.T) {
+	tests := []struct {
+		policy string
+		// Attributes of the cover geom, nil for no cover body
+		cover  map[string]string
+		meshes []string
+	}{
+		{assembly.HIDDEN_SKIP, nil, []string{"Plate.stl"}},
+		{assembly.HIDDEN_COLLISION, map[string]string{"name": "Cover_1", "type": "mesh", "mesh": "Cover", "group": "3", "rgba": "0 0 0 0"}, []string{"Cover.stl", "Plate.stl"}},
+		{assembly.HIDDEN_KEEP, map[string]string{"name": "Cover_1", "type": "mesh", "mesh": "Cover", "material": "appearance_808080ff"}, []string{"Cover.stl", "Plate.stl"}},
+	}
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			_, url := newFlatServer(t,
+				[]testPart{{id: "JHD", metadata: partMetadata("JHD", "Plate")}, {id: "COV", metadata: partMetadata("COV", "Cover")}, {id: "LID", metadata: partMetadata("LID", "Lid")}},
+				[]testInstance{
+					{id: "i1", name: "Plate <1>", part: "JHD"},
+					{id: "i2", name: "Cover <1>", part: "COV", hidden: true},
+					// Suppressed instances are left out whatever the policy
+					{id: "i3", name: "Plate <2>", part: "JHD", suppressed: true},
+					{id: "i4", name: "Lid <1>", part: "LID", suppressed: true},
+				},
+			)
+			opts := testOptions(t)
+			opts.Assembly = assembly.LoadOptions{Hidden: test.policy}
+
+			result, err := exporter.Export(context.Background(), url, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bodies := modelBodies(t, result.ModelPath)
+			want := []string{"Plate_1"}
+			if test.cover != nil {
+				want = append(want, "Cover_1")
+			}
+			if len(bodies) != len(want) {
+				t.Errorf("bodies %v, want %v", bodies, want)
+			}
+			for _, name := range want {
+				if _, ok := bodies[name]; !ok {
+					t.Errorf("no body %s in %v", name, bodies)
+				}
+			}
+			if cover, ok := bodies["Cover_1"]; ok && test.cover != nil {
+				checkGeom(t, cover, test.cover)
+			}
+			if got := fileNames(t, result.MeshDir); strings.Join(got, " ") != strings.Join(test.meshes, " ") {
+				t.Errorf("meshes %v, want %v", got, test.meshes)
+			}
+		})
+	}
+}
+
+func TestSecondExportReusesCachedMeshes(t *testing.T) {
+	s, url := newTestServer(t)
+	opts := testOptions(t)
+
+	for i := 0; i < 2; i++ {
+		opts.OutputDir = t.TempDir()
+		result, err := exporter.Export(context.Background(), url, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stl, err := os.ReadFile(filepath.Join(result.MeshDir, "Plate.stl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(stl) != TEST_STL {
+			t.Errorf("export %d wrote mesh %q, want %q", i+1, stl, TEST_STL)
+		}
+	}
+	if n := s.RequestCount("/stl-download/"); n != 1 {
+		t.Errorf("mesh downloaded %d times, want once", n)
+	}
+}
+
+func TestMetadataCacheAndOfflineExport(t *testing.T) {
+	s, url := newTestServer(t)
+	opts := testOptions(t)
+	first, err := exporter.Export(context.Background(), url, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Responses addressed by microversion are not requested again
+	opts.OutputDir = t.TempDir()
+	if _, err := exporter.Export(context.Background(), url, opts); err != nil {
+		t.Fatal(err)
+	}
+	immutable := []string{
+		"/parts/d/" + TEST_DOCUMENT + "/m/" + TEST_MICROVERSION + "/e/" + TEST_PART_STUDIO,
+		"/documents/d/" + TEST_DOCUMENT + "/m/" + TEST_MICROVERSION + "/elements",
+	}
+	for _, path := range immutable {
+		n := 0
+		for _, request := range s.Requests() {
+			if request == path {
+				n++
+			}
+		}
+		if n != 1 {
+			t.Errorf("%s requested %d times, want once", path, n)
+		}
+	}
+
+	// Offline, everything comes from the cache
+	s.Close()
+	requests := len(s.Requests())
+	opts.OutputDir = t.TempDir()
+	opts.Offline = true
+	offline, err := exporter.Export(context.Background(), url, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := mjcf.Diff(mustParse(t, first.ModelPath), mustParse(t, offline.ModelPath)); len(changes) != 0 {
+		t.Errorf("offline model differs: %v", changes)
+	}
+	if got := fileNames(t, offline.MeshDir); strings.Join(got, " ") != "Plate.stl" {
+		t.Errorf("offline meshes %v", got)
+	}
+
+	// An empty cache fails without trying the network
+	opts.CacheDir = t.TempDir()
+	if _, err := exporter.Export(context.Background(), url, opts); !errors.Is(err, onshape.ErrNotCached) {
+		t.Errorf("got %v, want ErrNotCached", err)
+	}
+	if n := len(s.Requests()); n != requests {
+		t.Errorf("%d requests while offline", n-requests)
+	}
+}
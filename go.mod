@@ -5,13 +5,14 @@ go 1.20
 require (
 	github.com/onshape-public/go-client v1.167.19458-7ff87863110f
 	github.com/ungerik/go3d v0.0.0-20220309204530-55ced4bcb334
+	golang.org/x/oauth2 v0.8.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/golang/protobuf v1.5.3 // indirect
 	golang.org/x/exp v0.0.0-20230522175609-2e198f4a06a1 // indirect
 	golang.org/x/net v0.10.0 // indirect
-	golang.org/x/oauth2 v0.8.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
 )
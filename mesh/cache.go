@@ -0,0 +1,70 @@
+package mesh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"onshape-mcjf-exporter/assembly"
+	"onshape-mcjf-exporter/internal/fileutil"
+	"onshape-mcjf-exporter/onshape"
+)
+
+// On-disk cache of exported meshes. Parts are always addressed by document
+// microversion, which never changes, so a cached mesh never goes stale.
+type Cache struct {
+	Dir string
+}
+
+func NewCache(dir string) *Cache {
+	if dir == "" {
+		dir = onshape.DefaultCacheDir()
+	}
+	return &Cache{Dir: filepath.Join(dir, "meshes")}
+}
+
+// Key covers everything that affects the exported bytes
+func CacheKey(part assembly.PartInfo, options ExportOptions) string {
+	tuple := strings.Join([]string{
+		part.Path.DocumentId,
+		part.Path.WVM,
+		part.Path.WVMId,
+		part.Path.ElementId,
+		part.Path.Configuration,
+		part.Id,
+		options.Units,
+		options.Mode,
+	}, "/")
+	sum := sha256.Sum256([]byte(tuple))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key[:2], key + ".stl")
+}
+
+func (c *Cache) Get(part assembly.PartInfo, options ExportOptions) ([]byte, bool) {
+	if part.Path.WVM != "m" {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(CacheKey(part, options)))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *Cache) Put(part assembly.PartInfo, options ExportOptions, data []byte) error {
+	// Workspace and version references can resolve to different geometry later
+	if part.Path.WVM != "m" {
+		return nil
+	}
+
+	if err := fileutil.WriteFileAtomic(c.path(CacheKey(part, options)), data); err != nil {
+		return fmt.Errorf("failed to store cached mesh: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Package mesh exports part geometry from Onshape as STL files.
+package mesh
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"onshape-mcjf-exporter/assembly"
+	"onshape-mcjf-exporter/onshape"
+)
+
+type ExportOptions struct {
+	Units string `json:"units"`
+	Mode  string `json:"mode"`
+	// Number of parts downloaded in parallel, one when unset
+	Concurrency int `json:"concurrency"`
+}
+
+// Saves each part as the file name returned by fileName plus ".stl" in path
+func SaveStlsToDir(c *onshape.Client, cache *Cache, parts []assembly.PartInfo, fileName func(part *assembly.PartInfo) string, path string, options ExportOptions) error {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return fmt.Errorf("failed to create stl directory at %s: %w", path, err)
+	}
+
+	workers := options.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	queue := make(chan assembly.PartInfo)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for part := range queue {
+				if err := saveStl(c, cache, part, path + "/" + fileName(&part) + ".stl", options); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, part := range parts {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		queue <- part
+	}
+	close(queue)
+	wg.Wait()
+
+	return firstErr
+}
+
+func saveStl(c *onshape.Client, cache *Cache, part assembly.PartInfo, file string, options ExportOptions) error {
+	if stl, ok := cache.Get(part, options); ok {
+		return os.WriteFile(file, stl, 0700)
+	}
+	if c.Offline {
+		return fmt.Errorf("stl for part %s: %w", part.Name, onshape.ErrNotCached)
+	}
+
+	stl, err := c.ExportStl(part.Path, part.Id, options.Units, options.Mode)
+	if err != nil {
+		return err
+	}
+	if err := cache.Put(part, options, stl); err != nil {
+		// Logged to stderr, stdout may carry a report
+		log.Printf("warning: failed to cache stl for %s -- %v", part.Name, err)
+	}
+	return os.WriteFile(file, stl, 0700)
+}
@@ -0,0 +1,331 @@
+package mjcf
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ungerik/go3d/float64/quaternion"
+	"github.com/ungerik/go3d/float64/vec3"
+	"onshape-mcjf-exporter/assembly"
+)
+
+// Significant digits written for every number, well below float64 precision
+// so that rounding noise does not show up in diffs between exports
+const FLOAT_DIGITS = 12
+
+// Coordinates smaller than this in model units are written as 0. Rotating a
+// position thousands of millimeters from the origin leaves noise around 1e-12.
+const COORDINATE_EPSILON = 1e-9
+
+func FloatStr(f float64) string {
+	return strconv.FormatFloat(f, 'g', FLOAT_DIGITS, 64)
+}
+
+// Like FloatStr, without rounding noise around 0 such as 2e-16 or -0
+func CoordinateStr(f float64) string {
+	if math.Abs(f) < COORDINATE_EPSILON {
+		f = 0
+	}
+	return FloatStr(f)
+}
+
+func Vec3Str(v vec3.T) string {
+	return CoordinateStr(v[0]) + " " + CoordinateStr(v[1]) + " " + CoordinateStr(v[2])
+}
+
+// Length given in meters, in model units
+func (u UnitOptions) LengthStr(meters float64) string {
+	return FloatStr(meters * u.LengthScale())
+}
+
+// Position given in meters, in model units
+func (u UnitOptions) PositionStr(v vec3.T) string {
+	scaled := v.Scaled(u.LengthScale())
+	return Vec3Str(scaled)
+}
+
+// Density given in kg/m³, in kg per cubic model unit
+func (u UnitOptions) DensityStr(density float64) string {
+	scale := u.LengthScale()
+	return FloatStr(density / (scale * scale * scale))
+}
+
+// Frame of the top level bodies in the world body, turning Onshape's Z-up
+// into Y-up when asked to
+func (u UnitOptions) RootTransform() assembly.Transform {
+	root := assembly.IdentityTransform()
+	if u.Up == UP_Y {
+		root.Quaternion = assembly.CanonicalQuaternion(quaternion.FromXAxisAngle(-math.Pi / 2))
+	}
+	return root
+}
+
+// Standard gravity pointing down the up axis, in model units
+func (u UnitOptions) GravityStr() string {
+	g := vec3.T{0, 0, -GRAVITY}
+	if u.Up == UP_Y {
+		g = vec3.T{0, -GRAVITY, 0}
+	}
+	return u.PositionStr(g)
+}
+
+// MJCF orders quaternions w, x, y, z
+func QuatStr(q quaternion.T) string {
+	return CoordinateStr(q[3]) + " " + CoordinateStr(q[0]) + " " + CoordinateStr(q[1]) + " " + CoordinateStr(q[2])
+}
+
+// One mesh asset per part, referenced by name from the part geoms and stored
+// in a file of the same name
+func MeshAssets(parts []assembly.PartInfo, names *Namer) []Element {
+	meshes := make([]Element, 0, len(parts))
+	for i := range parts {
+		name := names.Name(PartKey(&parts[i]))
+		meshes = append(meshes, NewInlineElement("mesh", Attributes{"name": name, "file": name + ".stl"}))
+	}
+	return meshes
+}
+
+// Identifies the occurrence by its instance id path from the root, so that
+// every instance of a repeated sub-assembly gets its own bodies
+func occurrenceKey(parent string, occ assembly.Occurrence) string {
+	return parent + "/" + occ.GetId()
+}
+
+// Instance id path of the key, e.g. "a1_i2", telling apart the occurrences of
+// one instance in repeated sub-assemblies whose instance names are equal too
+func keyQualifier(key string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(key, "/"), "/", "_")
+}
+
+// Override of the occurrence, matched by part or sub-assembly name and
+// instance name, nil if there is none
+func (m *ModelWriter) occurrenceOverride(occ assembly.Occurrence) *PartOverride {
+	switch o := occ.(type) {
+	case *assembly.AssemblyOccurrence:
+		return m.Options.PartOverride(o.Name, o.Assembly.Name)
+	case *assembly.PartOccurrence:
+		return m.Options.PartOverride(o.Part.Name, o.Name)
+	}
+	return nil
+}
+
+// Registers the names of the bodies and geoms of the occurrences and their
+// children, to be resolved before any body is built, and adds the PartKey of
+// their parts to parts. parents are the instance names of the enclosing
+// sub-assemblies, the nearest first. Excluded occurrences get no name, so
+// they never shift the names of the others.
+func (m *ModelWriter) addNames(occs []assembly.Occurrence, parent string, parents []string, assemblyName string, parts map[string]bool) {
+	for _, occ := range occs {
+		override := m.occurrenceOverride(occ)
+		if override != nil && override.Exclude {
+			continue
+		}
+		key := occurrenceKey(parent, occ)
+		fields := NameFields{Assembly: assemblyName, Instance: occ.GetName(), Id: occ.GetId(), Parents: parents, Qualifier: keyQualifier(key)}
+		var part *assembly.PartInfo
+		switch o := occ.(type) {
+		case *assembly.AssemblyOccurrence:
+			fields.Part = o.Assembly.Name
+			m.bodyNames.Add(key, fields)
+			m.addNames(o.Children, key, append([]string{o.Name}, parents...), o.Assembly.Name, parts)
+			continue
+		case *assembly.PartOccurrence:
+			part = o.Part
+		default:
+			continue
+		}
+		parts[PartKey(part)] = true
+		fields.Part = part.Name
+		m.bodyNames.Add(key, fields)
+		m.geomNames.Add(key, fields)
+		if override != nil && IsCollisionPrimitive(override.Collision) {
+			fields.Suffix = "collision"
+			m.geomNames.Add(key+"#collision", fields)
+		}
+	}
+}
+
+// Resolves the names of every body, geom and mesh in the model
+func (m *ModelWriter) assignNames(occs []assembly.Occurrence) {
+	naming := m.Options.Naming
+	documentName := GetDocumentName(m.Model)
+	m.bodyNames = NewNamer(naming.Body, DEFAULT_BODY_NAME)
+	m.geomNames = NewNamer(naming.Geom, DEFAULT_GEOM_NAME)
+	used := make(map[string]bool)
+	m.addNames(occs, "", nil, documentName, used)
+	m.bodyNames.Resolve()
+	m.geomNames.Resolve()
+
+	// Parts only instanced where an override excludes them get no mesh
+	parts := make([]assembly.PartInfo, 0, len(used))
+	for _, part := range m.Model.PartInfoList {
+		if used[PartKey(&part)] {
+			parts = append(parts, part)
+		}
+	}
+	m.meshNames = MeshNamer(parts, naming.Mesh, documentName)
+}
+
+// Body of the occurrence with default model options
+func OccurrenceBody(occ assembly.Occurrence) NestedElement {
+	m := &ModelWriter{Options: DefaultModelOptions()}
+	m.reset()
+	m.assignNames([]assembly.Occurrence{occ})
+	body, _ := m.occurrenceBody(occ, occurrenceKey("", occ), assembly.IdentityTransform())
+	return body
+}
+
+// Body of the occurrence with key and its children placed in frame, false if
+// a part override excludes it
+func (m *ModelWriter) occurrenceBody(occ assembly.Occurrence, key string, frame assembly.Transform) (NestedElement, bool) {
+	units := m.Options.Units
+	t := frame.Mul(occ.GetTransform())
+	body := NewNestedElement("body", Attributes{"name": m.bodyNames.Name(key), "pos": units.PositionStr(t.Translation), "quat": QuatStr(t.Quaternion)}, nil)
+
+	override := m.occurrenceOverride(occ)
+	if override != nil && override.Exclude {
+		return body, false
+	}
+
+	var part *assembly.PartInfo
+	switch o := occ.(type) {
+	case *assembly.AssemblyOccurrence:
+		for _, child := range o.Children {
+			if childBody, ok := m.occurrenceBody(child, occurrenceKey(key, child), assembly.IdentityTransform()); ok {
+				body.Children = append(body.Children, childBody)
+			}
+		}
+		return body, true
+	case *assembly.PartOccurrence:
+		part = o.Part
+	default:
+		return body, true
+	}
+
+	if part.StandardContent && m.Model.LoadOptions.StandardContent == assembly.STANDARD_CONTENT_MERGE_MASS {
+		if part.Mass != nil {
+			mass := *part.Mass
+			// Parts without a material in Onshape get the density of the table
+			if physical := m.Options.PhysicalMaterial(part.Material); !mass.HasMass && physical != nil {
+				mass.Mass = physical.Density * mass.Volume
+			}
+			geom := MassGeomAttributes(&mass, units)
+			geom["name"] = m.geomNames.Name(key)
+			body.AppendInline("geom", geom)
+		}
+		return body, true
+	}
+	hidden := occ.IsHidden() && m.Model.LoadOptions.HiddenCollisionOnly()
+	if part.StandardContent && hidden {
+		// Visual only, nothing is left of a hidden part
+		return body, true
+	}
+	mesh := m.meshNames.Name(PartKey(part))
+	if part.StandardContent {
+		geom := VisualGeomAttributes(mesh, m.partMaterial(part))
+		geom["name"] = m.geomNames.Name(key)
+		body.AppendInline("geom", geom)
+	} else {
+		material := DEFAULT_MATERIAL
+		if !hidden {
+			material = m.partMaterial(part)
+		}
+		physical := m.Options.PhysicalMaterial(part.Material)
+		if physical != nil && physical.Class != "" {
+			m.classes[physical.Class] = true
+		}
+		geoms := PartGeoms(mesh, override, material, physical, units)
+		names := []string{m.geomNames.Name(key), m.geomNames.Name(key + "#collision")}
+		if hidden && len(geoms) > 1 {
+			// Drop the visual mesh geom of a collision primitive
+			geoms, names = geoms[1:], names[1:]
+		}
+		for i, geom := range geoms {
+			geom["name"] = names[i]
+			if hidden {
+				HideGeom(geom)
+			}
+			body.AppendInline("geom", geom)
+		}
+	}
+	m.meshParts[PartKey(part)] = true
+	return body, true
+}
+
+// Keeps the mass and contacts of the geom but draws it nowhere, for parts
+// hidden in Onshape
+func HideGeom(geom Attributes) {
+	delete(geom, "material")
+	geom["group"] = "3"
+	geom["rgba"] = "0 0 0 0"
+}
+
+// Mesh geom that is drawn but neither collides nor adds mass
+func VisualGeomAttributes(mesh string, material string) Attributes {
+	geom := PartGeomAttributes(mesh, material)
+	geom["contype"] = "0"
+	geom["conaffinity"] = "0"
+	geom["density"] = "0"
+	return geom
+}
+
+// Invisible point mass standing in for a part whose geometry is left out
+func MassGeomAttributes(mass *assembly.MassProperties, units UnitOptions) Attributes {
+	return Attributes{
+		"type":        "sphere",
+		"size":        units.LengthStr(0.001),
+		"pos":         units.PositionStr(vec3.T(mass.Centroid)),
+		"mass":        FloatStr(mass.Mass),
+		"contype":     "0",
+		"conaffinity": "0",
+		"group":       "4",
+		"rgba":        "0 0 0 0",
+	}
+}
+
+func PartGeomAttributes(mesh string, material string) Attributes {
+	return Attributes{"type": "mesh", "mesh": mesh, "material": material}
+}
+
+// Geoms of a part with its physical material and then the override applied.
+// A primitive collision shape adds a second geom fitted to the mesh that
+// carries the mass and contacts, leaving the mesh geom visual only.
+func PartGeoms(mesh string, override *PartOverride, material string, physicalMaterial *PhysicalMaterial, units UnitOptions) []Attributes {
+	geom := PartGeomAttributes(mesh, material)
+	if override == nil {
+		if physicalMaterial != nil {
+			physicalMaterial.apply(geom, units)
+		}
+		return []Attributes{geom}
+	}
+
+	physical := geom
+	geoms := []Attributes{geom}
+	switch {
+	case override.Collision == COLLISION_NONE:
+		geom["contype"] = "0"
+		geom["conaffinity"] = "0"
+	case override.Collision == COLLISION_MESH:
+		// Overrides collision.strategy none from the geom defaults
+		geom["contype"] = "1"
+		geom["conaffinity"] = "1"
+	case IsCollisionPrimitive(override.Collision):
+		geom["contype"] = "0"
+		geom["conaffinity"] = "0"
+		geom["density"] = "0"
+		geom["group"] = "1"
+		physical = Attributes{"type": override.Collision, "mesh": mesh, "contype": "1", "conaffinity": "1", "group": "3"}
+		geoms = append(geoms, physical)
+	}
+	if physicalMaterial != nil {
+		physicalMaterial.apply(physical, units)
+	}
+	if override.Friction != "" {
+		physical["friction"] = override.Friction
+	}
+	if override.Density != 0 {
+		physical["density"] = units.DensityStr(override.Density)
+	}
+	return geoms
+}
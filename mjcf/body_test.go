@@ -0,0 +1,58 @@
+package mjcf
+
+import (
+	"testing"
+
+	"github.com/ungerik/go3d/float64/quaternion"
+	"github.com/ungerik/go3d/float64/vec3"
+	"onshape-mcjf-exporter/assembly"
+)
+
+func TestYUpPositionsHaveNoRoundingNoise(t *testing.T) {
+	tests := []struct {
+		units UnitOptions
+		pos   vec3.T
+		want  string
+	}{
+		{UnitOptions{Length: LENGTH_METER, Up: UP_Y}, vec3.T{0, 0, 5}, "0 5 0"},
+		{UnitOptions{Length: LENGTH_MILLIMETER, Up: UP_Y}, vec3.T{0, 0, 5}, "0 5000 0"},
+		{UnitOptions{Length: LENGTH_MILLIMETER, Up: UP_Y}, vec3.T{1.25, 3, 0}, "1250 0 -3000"},
+		{UnitOptions{Length: LENGTH_MILLIMETER, Up: UP_Z}, vec3.T{0.001, 0, 2e-16}, "1 0 0"},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			frame := test.units.RootTransform().Mul(assembly.Transform{Translation: test.pos, Quaternion: quaternion.Ident})
+			if got := test.units.PositionStr(frame.Translation); got != test.want {
+				t.Errorf("position %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExcludedOccurrencesDoNotShiftNames(t *testing.T) {
+	parts := []assembly.PartInfo{{Id: "JHD", Name: "Bracket"}, {Id: "JKD", Name: "Screw"}}
+	occurrence := func(id string, name string, part *assembly.PartInfo) assembly.Occurrence {
+		return &assembly.PartOccurrence{BaseOccurrence: assembly.BaseOccurrence{Transform: assembly.IdentityTransform(), Id: id, Path: []string{id}, Name: name}, Part: part}
+	}
+	writer := NewModelWriter(assembly.ModelData{
+		PartInfoList: parts,
+		Occurrences: []assembly.Occurrence{
+			occurrence("i1", "Bracket <1>", &parts[0]),
+			occurrence("i2", "Bracket <2>", &parts[0]),
+			occurrence("i3", "Screw <1>", &parts[1]),
+		},
+	})
+	writer.Options.Naming.Body = "{part}_{n}"
+	writer.Options.Parts = []PartOverride{{Match: "Bracket <1>", Exclude: true}, {Match: "Screw*", Exclude: true}}
+	writer.MakeModel()
+
+	if got := writer.bodyNames.Name("/i2"); got != "Bracket_1" {
+		t.Errorf("remaining bracket named %q, want Bracket_1", got)
+	}
+	if got := writer.bodyNames.Name("/i1"); got != "" {
+		t.Errorf("excluded bracket named %q", got)
+	}
+	if got := writer.MeshName(&parts[1]); got != "" {
+		t.Errorf("excluded screw has mesh %q", got)
+	}
+}
@@ -0,0 +1,119 @@
+package mjcf
+
+import (
+	"sort"
+)
+
+type ChangeKind int
+
+const (
+	Added ChangeKind = iota
+	Removed
+	Modified
+)
+
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	return []byte([...]string{"added", "removed", "modified"}[k]), nil
+}
+
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	Path string     `json:"path"`
+	// Set for attribute changes, empty when a whole element was added or removed
+	Attr string `json:"attr,omitempty"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	switch {
+	case c.Attr == "" && c.Kind == Added:
+		return "+ " + c.Path
+	case c.Attr == "" && c.Kind == Removed:
+		return "- " + c.Path
+	case c.Kind == Added:
+		return "+ " + c.Path + " " + c.Attr + "=\"" + c.New + "\""
+	case c.Kind == Removed:
+		return "- " + c.Path + " " + c.Attr + "=\"" + c.Old + "\""
+	default:
+		return "~ " + c.Path + " " + c.Attr + ": \"" + c.Old + "\" -> \"" + c.New + "\""
+	}
+}
+
+// Structural difference between two models. Elements are matched by tag and
+// name, or by position among unnamed siblings with the same tag, so
+// attribute order and formatting never show up as changes.
+func Diff(a *Node, b *Node) []Change {
+	changes := make([]Change, 0)
+	diffNode(a.Tag(), a, b, &changes)
+	return changes
+}
+
+func diffNode(path string, a *Node, b *Node, changes *[]Change) {
+	oldAttrs := make(map[string]string)
+	for _, attr := range a.Attrs {
+		oldAttrs[attr.Name.Local] = attr.Value
+	}
+	newAttrs := make(map[string]string)
+	for _, attr := range b.Attrs {
+		newAttrs[attr.Name.Local] = attr.Value
+	}
+
+	for _, name := range sortedKeys(oldAttrs, newAttrs) {
+		oldValue, inOld := oldAttrs[name]
+		newValue, inNew := newAttrs[name]
+		switch {
+		case !inNew:
+			*changes = append(*changes, Change{Kind: Removed, Path: path, Attr: name, Old: oldValue})
+		case !inOld:
+			*changes = append(*changes, Change{Kind: Added, Path: path, Attr: name, New: newValue})
+		case oldValue != newValue:
+			*changes = append(*changes, Change{Kind: Modified, Path: path, Attr: name, Old: oldValue, New: newValue})
+		}
+	}
+
+	oldChildren, oldOrder := indexChildren(a)
+	newChildren, newOrder := indexChildren(b)
+
+	for _, segment := range oldOrder {
+		if newChild, ok := newChildren[segment]; ok {
+			diffNode(path + "/" + segment, oldChildren[segment], newChild, changes)
+		} else {
+			*changes = append(*changes, Change{Kind: Removed, Path: path + "/" + segment})
+		}
+	}
+	for _, segment := range newOrder {
+		if _, ok := oldChildren[segment]; !ok {
+			*changes = append(*changes, Change{Kind: Added, Path: path + "/" + segment})
+		}
+	}
+}
+
+func indexChildren(n *Node) (map[string]*Node, []string) {
+	index := make(map[string]*Node)
+	order := make([]string, 0, len(n.Children))
+	counts := make(map[string]int)
+	for i := range n.Children {
+		child := &n.Children[i]
+		segment := child.segment(counts[child.Tag()])
+		counts[child.Tag()]++
+		index[segment] = child
+		order = append(order, segment)
+	}
+	return index, order
+}
+
+func sortedKeys(maps ...map[string]string) []string {
+	seen := make(map[string]bool)
+	keys := make([]string, 0)
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,39 @@
+package mjcf
+
+import (
+	"fmt"
+	"strconv"
+
+	"onshape-mcjf-exporter/assembly"
+)
+
+// Material of parts without an appearance, colored by the default material rgba
+const DEFAULT_MATERIAL = "body"
+
+// Onshape colors and opacity are 0-255, MJCF rgba is 0-1
+func ColorRgba(c assembly.Color) string {
+	channel := func(v uint8) string {
+		return strconv.FormatFloat(float64(v) / 255, 'g', 4, 64)
+	}
+	return channel(c.R) + " " + channel(c.G) + " " + channel(c.B) + " " + channel(c.A)
+}
+
+// Named after the color so the name stays the same across exports
+func AppearanceMaterialName(c assembly.Color) string {
+	return fmt.Sprintf("appearance_%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// Material for the part's appearance, added to the assets on first use
+func (m *ModelWriter) partMaterial(part *assembly.PartInfo) string {
+	if part.Appearance == (assembly.Color{}) {
+		// No appearance in the part metadata
+		return DEFAULT_MATERIAL
+	}
+	if name, ok := m.materialNames[part.Appearance]; ok {
+		return name
+	}
+	name := AppearanceMaterialName(part.Appearance)
+	m.materialNames[part.Appearance] = name
+	m.materials = append(m.materials, NewInlineElement("material", Attributes{"name": name, "rgba": ColorRgba(part.Appearance)}))
+	return name
+}
@@ -0,0 +1,198 @@
+package mjcf
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"onshape-mcjf-exporter/assembly"
+)
+
+// Templates used for the naming options left empty
+const (
+	DEFAULT_BODY_NAME = "{instance}"
+	DEFAULT_GEOM_NAME = "{instance}"
+	DEFAULT_MESH_NAME = "{part}"
+)
+
+// Given to names that sanitize to nothing
+const UNNAMED = "unnamed"
+
+// Values of the placeholders of a name template
+type NameFields struct {
+	// Enclosing assembly, the document at the top level
+	Assembly string
+	// Part name, or the sub-assembly name for the body of a sub-assembly
+	Part string
+	// Instance name shown in the Onshape assembly tree
+	Instance string
+	// Onshape instance or part id
+	Id string
+	// Instance names of the enclosing sub-assemblies, the nearest first,
+	// appended one by one to names that are still shared
+	Parents []string
+	// Appended to names that the parents do not tell apart, Id when empty
+	Qualifier string
+	// Appended after the template, e.g. "collision" for a second geom
+	Suffix string
+}
+
+// Assigns names from a template that are safe in MJCF and file names and
+// unique among each other. Every name is added with Add before Resolve
+// assigns them all at once, so a name depends on the Onshape ids of the
+// entities sharing it and not on the order the assembly is walked in.
+type Namer struct {
+	template string
+	fields   map[string]NameFields
+	names    map[string]string
+}
+
+func NewNamer(template string, fallback string) *Namer {
+	if template == "" {
+		template = fallback
+	}
+	return &Namer{
+		template: template,
+		fields:   make(map[string]NameFields),
+		names:    make(map[string]string),
+	}
+}
+
+// Registers the entity with the stable key, e.g. its instance id path
+func (n *Namer) Add(key string, fields NameFields) {
+	n.fields[key] = fields
+}
+
+// Name of the entity added with key, empty before Resolve
+func (n *Namer) Name(key string) string {
+	return n.names[key]
+}
+
+func (n *Namer) Resolve() {
+	keys := make([]string, 0, len(n.fields))
+	for key := range n.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// {n} numbers the entities whose names differ in nothing else, in key order
+	counts := make(map[string]int)
+	candidates := make(map[string]string, len(keys))
+	for _, key := range keys {
+		fields := n.fields[key]
+		name := expandName(n.template, fields, "{n}")
+		counts[name]++
+		name = expandName(n.template, fields, strconv.Itoa(counts[name]))
+		if fields.Suffix != "" {
+			name += "_" + fields.Suffix
+		}
+		candidates[key] = SanitizeName(name)
+	}
+
+	// Names still shared get the names of as many enclosing sub-assemblies as
+	// it takes to tell them apart, e.g. "Foot_1_Leg_2"
+	base := make(map[string]string, len(keys))
+	for key, name := range candidates {
+		base[key] = name
+	}
+	for depth := 1; ; depth++ {
+		shared := sharedNames(candidates)
+		qualified := false
+		for _, key := range keys {
+			parents := n.fields[key].Parents
+			if shared[candidates[key]] > 1 && depth <= len(parents) {
+				names := []string{base[key]}
+				for _, parent := range parents[:depth] {
+					names = append(names, SanitizeName(parent))
+				}
+				candidates[key] = strings.Join(names, "_")
+				qualified = true
+			}
+		}
+		if !qualified {
+			break
+		}
+	}
+
+	// Then the qualifier of their entity, which changes when the CAD is edited
+	shared := sharedNames(candidates)
+	for _, key := range keys {
+		fields := n.fields[key]
+		qualifier := fields.Qualifier
+		if qualifier == "" {
+			qualifier = fields.Id
+		}
+		if name := candidates[key]; shared[name] > 1 && qualifier != "" {
+			candidates[key] = SanitizeName(name + "_" + qualifier)
+		}
+	}
+
+	// Anything left is numbered, again in key order
+	used := make(map[string]bool, len(keys))
+	n.names = make(map[string]string, len(keys))
+	for _, key := range keys {
+		name := candidates[key]
+		for i := 2; used[name]; i++ {
+			name = candidates[key] + "_" + strconv.Itoa(i)
+		}
+		used[name] = true
+		n.names[key] = name
+	}
+}
+
+// Number of entities with each name
+func sharedNames(names map[string]string) map[string]int {
+	shared := make(map[string]int, len(names))
+	for _, name := range names {
+		shared[name]++
+	}
+	return shared
+}
+
+func expandName(template string, fields NameFields, number string) string {
+	return strings.NewReplacer(
+		"{assembly}", fields.Assembly,
+		"{part}", fields.Part,
+		"{instance}", fields.Instance,
+		"{id}", fields.Id,
+		"{n}", number,
+	).Replace(template)
+}
+
+// Keeps ASCII letters, digits, '_', '-' and '.', replacing every other run of
+// characters with a single '_', e.g. "Plate <1>" becomes "Plate_1". The
+// result is a valid MJCF name and file name on every platform.
+func SanitizeName(name string) string {
+	var b strings.Builder
+	replaced := false
+	for _, r := range name {
+		if r < 128 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.') {
+			b.WriteRune(r)
+			replaced = false
+		} else if !replaced {
+			b.WriteByte('_')
+			replaced = true
+		}
+	}
+	sanitized := strings.TrimLeft(strings.Trim(b.String(), "_"), ".")
+	if sanitized == "" {
+		return UNNAMED
+	}
+	return sanitized
+}
+
+// Identifies the part across exports, the same for every instance of it
+func PartKey(part *assembly.PartInfo) string {
+	path := part.Path
+	return strings.Join([]string{path.DocumentId, path.ElementId, part.Id, path.Configuration, path.WVMId}, "/")
+}
+
+// Names of the part meshes, which are also their STL file names
+func MeshNamer(parts []assembly.PartInfo, template string, documentName string) *Namer {
+	namer := NewNamer(template, DEFAULT_MESH_NAME)
+	for i := range parts {
+		namer.Add(PartKey(&parts[i]), NameFields{Assembly: documentName, Part: parts[i].Name, Id: parts[i].Id})
+	}
+	namer.Resolve()
+	return namer
+}
@@ -0,0 +1,51 @@
+package mjcf
+
+import "testing"
+
+func TestSharedNamesAreQualifiedByParentsBeforeIds(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]NameFields
+		want   map[string]string
+	}{
+		{
+			"nearest parent",
+			map[string]NameFields{
+				"/a1/i1": {Instance: "Foot <1>", Parents: []string{"Leg <1>"}, Qualifier: "a1_i1"},
+				"/a2/i1": {Instance: "Foot <1>", Parents: []string{"Leg <2>"}, Qualifier: "a2_i1"},
+			},
+			map[string]string{"/a1/i1": "Foot_1_Leg_1", "/a2/i1": "Foot_1_Leg_2"},
+		},
+		{
+			"as many parents as needed",
+			map[string]NameFields{
+				"/r1/a1/i1": {Instance: "Foot <1>", Parents: []string{"Leg <1>", "Robot <1>"}, Qualifier: "r1_a1_i1"},
+				"/r2/a1/i1": {Instance: "Foot <1>", Parents: []string{"Leg <1>", "Robot <2>"}, Qualifier: "r2_a1_i1"},
+				"/i2":       {Instance: "Foot <2>", Qualifier: "i2"},
+			},
+			map[string]string{"/r1/a1/i1": "Foot_1_Leg_1_Robot_1", "/r2/a1/i1": "Foot_1_Leg_1_Robot_2", "/i2": "Foot_2"},
+		},
+		{
+			"ids when the parents are equal too",
+			map[string]NameFields{
+				"/a1/i1": {Instance: "Foot", Parents: []string{"Leg"}, Qualifier: "a1_i1"},
+				"/a2/i1": {Instance: "Foot", Parents: []string{"Leg"}, Qualifier: "a2_i1"},
+			},
+			map[string]string{"/a1/i1": "Foot_Leg_a1_i1", "/a2/i1": "Foot_Leg_a2_i1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			namer := NewNamer("", DEFAULT_BODY_NAME)
+			for key, fields := range test.fields {
+				namer.Add(key, fields)
+			}
+			namer.Resolve()
+			for key, want := range test.want {
+				if got := namer.Name(key); got != want {
+					t.Errorf("%s named %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package mjcf
+
+import "path"
+
+// Collision strategies
+const (
+	// Geoms collide using the convex hull of their mesh
+	COLLISION_MESH = "mesh"
+	// Geoms are visual only
+	COLLISION_NONE = "none"
+)
+
+// Primitive collision shapes of part overrides, fitted by MuJoCo to the part
+// mesh while the mesh itself stays as the visual geom
+var CollisionPrimitives = []string{"box", "sphere", "capsule", "cylinder", "ellipsoid"}
+
+// Units and frame of the model
+const (
+	LENGTH_METER      = "m"
+	LENGTH_MILLIMETER = "mm"
+	ANGLE_RADIAN      = "radian"
+	ANGLE_DEGREE      = "degree"
+	// Onshape's convention
+	UP_Z = "z"
+	// Rotates the whole model -90 degrees about x for Y-up consumers
+	UP_Y = "y"
+)
+
+var (
+	LengthUnits = []string{LENGTH_METER, LENGTH_MILLIMETER}
+	AngleUnits  = []string{ANGLE_RADIAN, ANGLE_DEGREE}
+	UpAxes      = []string{UP_Z, UP_Y}
+)
+
+// Standard gravity in m/s²
+const GRAVITY = 9.81
+
+// Unit system of every length, density and angle in the model. Values given
+// in the config, such as gravity, are taken to be in these units already.
+type UnitOptions struct {
+	Length string `json:"length_unit,omitempty"`
+	Angle  string `json:"angle_unit,omitempty"`
+	Up     string `json:"up_axis,omitempty"`
+}
+
+// Model lengths per meter
+func (u UnitOptions) LengthScale() float64 {
+	if u.Length == LENGTH_MILLIMETER {
+		return 1000
+	}
+	return 1
+}
+
+// Units argument of Onshape's STL export matching the model lengths
+func (u UnitOptions) StlUnits() string {
+	if u.Length == LENGTH_MILLIMETER {
+		return "millimeter"
+	}
+	return "meter"
+}
+
+func (u UnitOptions) angle() string {
+	if u.Angle == "" {
+		return ANGLE_RADIAN
+	}
+	return u.Angle
+}
+
+// Values of the <option> element
+type PhysicsOptions struct {
+	Timestep float64 `json:"timestep"`
+	// Three numbers in model units, e.g. "0 0 -9.81". Defaults to standard
+	// gravity along the up axis.
+	Gravity string `json:"gravity,omitempty"`
+	// Euler, RK4, implicit or implicitfast
+	Integrator string `json:"integrator,omitempty"`
+}
+
+// Geom defaults for parts without more specific settings
+type MaterialOptions struct {
+	Rgba     string  `json:"rgba,omitempty"`
+	Density  float64 `json:"density,omitempty"`
+	Friction string  `json:"friction,omitempty"`
+}
+
+type CollisionOptions struct {
+	Strategy string `json:"strategy"`
+}
+
+// Rule applied to the parts whose part or instance name matches the glob
+// pattern Match, e.g. "Screw*". Exclude also applies to sub-assembly
+// instances, dropping them with everything inside.
+type PartOverride struct {
+	Match   string `json:"match"`
+	Exclude bool   `json:"exclude,omitempty"`
+	// COLLISION_MESH, COLLISION_NONE or one of CollisionPrimitives
+	Collision string  `json:"collision,omitempty"`
+	Friction  string  `json:"friction,omitempty"`
+	Density   float64 `json:"density,omitempty"`
+}
+
+// Name templates of generated elements, e.g. "{assembly}_{part}_{n}". Names
+// are sanitized and made unique, see Namer.
+type NamingOptions struct {
+	Body string `json:"body,omitempty"`
+	Geom string `json:"geom,omitempty"`
+	Mesh string `json:"mesh,omitempty"`
+}
+
+// Everything about the model that is not read from Onshape
+type ModelOptions struct {
+	Units           UnitOptions
+	Physics         PhysicsOptions
+	DefaultMaterial MaterialOptions
+	Collision       CollisionOptions
+	// Onshape material names to physical materials, before BuiltinMaterials
+	Materials []MaterialRule
+	Parts     []PartOverride
+	Naming    NamingOptions
+}
+
+func DefaultModelOptions() ModelOptions {
+	return ModelOptions{
+		Units:           UnitOptions{Length: LENGTH_METER, Angle: ANGLE_RADIAN, Up: UP_Z},
+		Physics:         PhysicsOptions{Timestep: 0.005},
+		DefaultMaterial: MaterialOptions{Rgba: "0.8 0.6 .4 1"},
+		Collision:       CollisionOptions{Strategy: COLLISION_MESH},
+		Naming:          NamingOptions{Body: DEFAULT_BODY_NAME, Geom: DEFAULT_GEOM_NAME, Mesh: DEFAULT_MESH_NAME},
+	}
+}
+
+// First part override matching any of the names, nil if there is none
+func (o *ModelOptions) PartOverride(names ...string) *PartOverride {
+	for i := range o.Parts {
+		for _, name := range names {
+			if name == "" {
+				continue
+			}
+			if ok, _ := path.Match(o.Parts[i].Match, name); ok {
+				return &o.Parts[i]
+			}
+		}
+	}
+	return nil
+}
+
+func IsCollisionPrimitive(collision string) bool {
+	for _, primitive := range CollisionPrimitives {
+		if collision == primitive {
+			return true
+		}
+	}
+	return false
+}
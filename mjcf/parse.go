@@ -0,0 +1,75 @@
+package mjcf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Generic MJCF element as read back from a file
+type Node struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []Node     `xml:",any"`
+}
+
+func Parse(r io.Reader) (*Node, error) {
+	var root Node
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, fmt.Errorf("failed to parse mjcf: %w", err)
+	}
+	return &root, nil
+}
+
+func ParseFile(path string) (*Node, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	root, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return root, nil
+}
+
+func (n *Node) Tag() string {
+	return n.XMLName.Local
+}
+
+func (n *Node) Attr(name string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// Path segment identifying n among its siblings, by name when it has one
+func (n *Node) segment(index int) string {
+	if name, ok := n.Attr("name"); ok {
+		return n.Tag() + "[name=" + name + "]"
+	}
+	return n.Tag() + "[" + strconv.Itoa(index) + "]"
+}
+
+// Calls visit for n and every descendant with its path from the root
+func (n *Node) Walk(visit func(path string, node *Node)) {
+	n.walk(n.Tag(), visit)
+}
+
+func (n *Node) walk(path string, visit func(path string, node *Node)) {
+	visit(path, n)
+	counts := make(map[string]int)
+	for i := range n.Children {
+		child := &n.Children[i]
+		index := counts[child.Tag()]
+		counts[child.Tag()]++
+		child.walk(path + "/" + child.segment(index), visit)
+	}
+}
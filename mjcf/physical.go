@@ -0,0 +1,92 @@
+package mjcf
+
+import (
+	"path"
+	"strings"
+)
+
+// Contact and mass settings of an Onshape material
+type PhysicalMaterial struct {
+	// kg/m³, used by MuJoCo to compute the mass from the mesh volume
+	Density  float64 `json:"density,omitempty"`
+	Friction string  `json:"friction,omitempty"`
+	Solref   string  `json:"solref,omitempty"`
+	Solimp   string  `json:"solimp,omitempty"`
+	// Default class given to the geoms, declared empty in the model so it
+	// can be filled in by an including model
+	Class string `json:"class,omitempty"`
+}
+
+// Physical material of the Onshape materials whose name matches the glob
+// pattern Match, ignoring case, e.g. "Aluminum*"
+type MaterialRule struct {
+	Match string `json:"match"`
+	PhysicalMaterial
+}
+
+// Onshape library materials by family, consulted after the rules of the config
+var BuiltinMaterials = []MaterialRule{
+	{"stainless steel*", PhysicalMaterial{Density: 8000, Friction: "0.5 0.005 0.0001"}},
+	{"steel*", PhysicalMaterial{Density: 7850, Friction: "0.5 0.005 0.0001"}},
+	{"*iron*", PhysicalMaterial{Density: 7200, Friction: "0.5 0.005 0.0001"}},
+	{"aluminum*", PhysicalMaterial{Density: 2700, Friction: "0.6 0.005 0.0001"}},
+	{"titanium*", PhysicalMaterial{Density: 4430, Friction: "0.5 0.005 0.0001"}},
+	{"brass*", PhysicalMaterial{Density: 8500, Friction: "0.5 0.005 0.0001"}},
+	{"copper*", PhysicalMaterial{Density: 8960, Friction: "0.5 0.005 0.0001"}},
+	{"abs*", PhysicalMaterial{Density: 1040, Friction: "0.5 0.005 0.0001"}},
+	{"pla*", PhysicalMaterial{Density: 1240, Friction: "0.5 0.005 0.0001"}},
+	{"petg*", PhysicalMaterial{Density: 1270, Friction: "0.5 0.005 0.0001"}},
+	{"nylon*", PhysicalMaterial{Density: 1140, Friction: "0.4 0.005 0.0001"}},
+	{"acetal*", PhysicalMaterial{Density: 1410, Friction: "0.3 0.005 0.0001"}},
+	{"delrin*", PhysicalMaterial{Density: 1410, Friction: "0.3 0.005 0.0001"}},
+	{"polycarbonate*", PhysicalMaterial{Density: 1200, Friction: "0.5 0.005 0.0001"}},
+	{"acrylic*", PhysicalMaterial{Density: 1180, Friction: "0.5 0.005 0.0001"}},
+	{"*polyethylene*", PhysicalMaterial{Density: 950, Friction: "0.3 0.005 0.0001"}},
+	{"carbon fiber*", PhysicalMaterial{Density: 1600, Friction: "0.4 0.005 0.0001"}},
+	{"wood*", PhysicalMaterial{Density: 700, Friction: "0.5 0.005 0.0001"}},
+	// Soft materials get more grip and a softer, more damped contact
+	{"*rubber*", PhysicalMaterial{Density: 1100, Friction: "1.2 0.01 0.001", Solref: "0.02 1", Solimp: "0.9 0.95 0.001"}},
+	{"silicone*", PhysicalMaterial{Density: 1100, Friction: "1 0.01 0.001", Solref: "0.02 1", Solimp: "0.9 0.95 0.001"}},
+	{"tpu*", PhysicalMaterial{Density: 1210, Friction: "1 0.01 0.001", Solref: "0.02 1", Solimp: "0.9 0.95 0.001"}},
+}
+
+func matchMaterial(rules []MaterialRule, name string) *PhysicalMaterial {
+	name = strings.ToLower(name)
+	for i := range rules {
+		if ok, _ := path.Match(strings.ToLower(rules[i].Match), name); ok {
+			return &rules[i].PhysicalMaterial
+		}
+	}
+	return nil
+}
+
+// Physical material for an Onshape material name, from the configured rules
+// first and BuiltinMaterials second. Nil for unknown or empty names.
+func (o *ModelOptions) PhysicalMaterial(name string) *PhysicalMaterial {
+	if name == "" {
+		return nil
+	}
+	if material := matchMaterial(o.Materials, name); material != nil {
+		return material
+	}
+	return matchMaterial(BuiltinMaterials, name)
+}
+
+// Sets the attributes the material defines on a geom
+func (p *PhysicalMaterial) apply(geom Attributes, units UnitOptions) {
+	if p.Density != 0 {
+		geom["density"] = units.DensityStr(p.Density)
+	}
+	if p.Friction != "" {
+		geom["friction"] = p.Friction
+	}
+	if p.Solref != "" {
+		geom["solref"] = p.Solref
+	}
+	if p.Solimp != "" {
+		geom["solimp"] = p.Solimp
+	}
+	if p.Class != "" {
+		geom["class"] = p.Class
+	}
+}
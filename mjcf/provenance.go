@@ -0,0 +1,67 @@
+package mjcf
+
+import (
+	"strings"
+	"time"
+)
+
+// Where an exported model comes from, written into its header comment
+type Provenance struct {
+	// Url the export was requested with
+	Source        string
+	DocumentId    string
+	WorkspaceId   string
+	VersionId     string
+	VersionName   string
+	Microversion  string
+	ElementId     string
+	Configuration string
+	ExportedAt    time.Time
+	ToolVersion   string
+}
+
+// Header comment naming the exact CAD state of the model
+func (p *Provenance) Comment() string {
+	exportedAt := ""
+	if !p.ExportedAt.IsZero() {
+		exportedAt = p.ExportedAt.UTC().Format(time.RFC3339)
+	}
+
+	lines := []struct {
+		label string
+		value string
+	}{
+		{"Source", p.Source},
+		{"Document", p.DocumentId},
+		{"Workspace", p.WorkspaceId},
+		{"Version", p.VersionId},
+		{"Version name", p.VersionName},
+		{"Microversion", p.Microversion},
+		{"Element", p.ElementId},
+		{"Configuration", p.Configuration},
+		{"Exported at", exportedAt},
+	}
+
+	str := "<!--\n  Generated by Onshape MCJF Exporter"
+	if p.ToolVersion != "" {
+		str += " " + commentSafe(p.ToolVersion)
+	}
+	str += ".\n\n"
+	for _, line := range lines {
+		if line.value != "" {
+			str += "  " + line.label + ":" + strings.Repeat(" ", 15-len(line.label)) + commentSafe(line.value) + "\n"
+		}
+	}
+	if p.Microversion == "" && p.VersionId == "" {
+		str += "\n  Exported from a workspace, which may have changed since.\n"
+	}
+	return str + "\n  Do not modify.\n-->\n"
+}
+
+// XML comments must not contain "--"
+func commentSafe(s string) string {
+	for strings.Contains(s, "--") {
+		s = strings.ReplaceAll(s, "--", "-")
+	}
+	return s
+}
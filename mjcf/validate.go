@@ -0,0 +1,130 @@
+package mjcf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Element kinds whose names must be unique within the model
+var namedKinds = map[string]bool{
+	"body":     true,
+	"geom":     true,
+	"joint":    true,
+	"site":     true,
+	"mesh":     true,
+	"material": true,
+	"texture":  true,
+}
+
+// Number of values expected by numeric attributes
+var vectorAttributes = map[string]int{
+	"pos":   3,
+	"quat":  4,
+	"rgba":  4,
+	"euler": 3,
+	"axis":  3,
+}
+
+// Checks the structure of a parsed model: unique names, resolvable asset
+// references, well formed numeric attributes and mesh files present relative
+// to baseDir.
+func Validate(root *Node, baseDir string) []error {
+	errs := make([]error, 0)
+	fail := func(path string, format string, args ...any) {
+		errs = append(errs, &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if root.Tag() != "mujoco" {
+		fail(root.Tag(), "root element must be <mujoco>")
+		return errs
+	}
+
+	meshDir := ""
+	names := make(map[string]map[string]string)
+	root.Walk(func(path string, node *Node) {
+		if node.Tag() == "compiler" {
+			if dir, ok := node.Attr("meshdir"); ok {
+				meshDir = dir
+			}
+		}
+
+		if name, ok := node.Attr("name"); ok && namedKinds[node.Tag()] {
+			if names[node.Tag()] == nil {
+				names[node.Tag()] = make(map[string]string)
+			}
+			if first, dup := names[node.Tag()][name]; dup {
+				fail(path, "duplicate %s name %q, first used at %s", node.Tag(), name, first)
+			} else {
+				names[node.Tag()][name] = path
+			}
+		}
+
+		for _, attr := range node.Attrs {
+			count, ok := vectorAttributes[attr.Name.Local]
+			if !ok {
+				continue
+			}
+			values := strings.Fields(attr.Value)
+			if len(values) != count {
+				fail(path, "%s needs %d values, got %q", attr.Name.Local, count, attr.Value)
+				continue
+			}
+			for _, v := range values {
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					fail(path, "%s value %q is not a number", attr.Name.Local, v)
+				} else if attr.Name.Local == "rgba" && (f < 0 || f > 1) {
+					fail(path, "rgba value %s outside of 0-1", v)
+				}
+			}
+		}
+	})
+
+	if !filepath.IsAbs(meshDir) {
+		meshDir = filepath.Join(baseDir, meshDir)
+	}
+
+	refs := []struct {
+		tag  string
+		attr string
+		kind string
+	}{
+		{"geom", "mesh", "mesh"},
+		{"geom", "material", "material"},
+		{"material", "texture", "texture"},
+	}
+	root.Walk(func(path string, node *Node) {
+		for _, ref := range refs {
+			if node.Tag() != ref.tag {
+				continue
+			}
+			if name, ok := node.Attr(ref.attr); ok {
+				if _, found := names[ref.kind][name]; !found {
+					fail(path, "references undefined %s %q", ref.kind, name)
+				}
+			}
+		}
+
+		if node.Tag() == "mesh" {
+			if file, ok := node.Attr("file"); ok {
+				if _, err := os.Stat(filepath.Join(meshDir, file)); err != nil {
+					fail(path, "mesh file %s not found in %s", file, meshDir)
+				}
+			}
+		}
+	})
+
+	return errs
+}
@@ -0,0 +1,305 @@
+// Package mjcf builds and serializes MuJoCo MJCF models.
+package mjcf
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+
+	"onshape-mcjf-exporter/assembly"
+)
+
+type Attributes map[string]string
+
+type Element interface {
+	GetTag() string
+	GetAttributes() Attributes
+}
+
+type BaseElement struct {
+	Tag string
+	Attributes Attributes
+}
+
+type InlineElement struct {
+	BaseElement
+}
+
+func (i *InlineElement) TagStr() string {
+	return TagBodyStr(i.Tag, i.Attributes) + "/>\n"
+}
+
+func NewInlineElement(tag string, attrs Attributes) InlineElement {
+	return InlineElement{
+		BaseElement: BaseElement{Tag: tag, Attributes: attrs},
+	}
+}
+
+type NestedElement struct {
+	BaseElement
+	Children []Element
+}
+
+func NewNestedElement(tag string, attrs Attributes, children []Element) NestedElement {
+	return NestedElement{
+		BaseElement: BaseElement{Tag: tag, Attributes: attrs},
+		Children: children,
+	}
+}
+
+func (n *NestedElement) StartTagStr() string {
+	return TagBodyStr(n.Tag, n.Attributes) + ">\n"
+}
+
+func (n *NestedElement) EndTagStr() string {
+	return "</" + n.Tag + ">\n"
+}
+
+// Attributes written first, in this order. The others follow sorted by name
+// so that the same model is always written the same way.
+var attributeOrder = []string{"name", "class", "type"}
+
+func sortedAttributeNames(attrs Attributes) []string {
+	names := make([]string, 0, len(attrs))
+	for _, name := range attributeOrder {
+		if _, ok := attrs[name]; ok {
+			names = append(names, name)
+		}
+	}
+	rest := make([]string, 0, len(attrs))
+	for name := range attrs {
+		leading := false
+		for _, l := range attributeOrder {
+			leading = leading || name == l
+		}
+		if !leading {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+// Start of the tag with its attributes, values escaped since document names
+// and config values such as classes are written as they are
+func TagBodyStr(tag string, attrs Attributes) string {
+	var str strings.Builder
+	str.WriteString("<" + tag)
+	for _, name := range sortedAttributeNames(attrs) {
+		str.WriteString(" " + name + "=\"")
+		xml.EscapeText(&str, []byte(attrs[name]))
+		str.WriteString("\"")
+	}
+	return str.String()
+}
+
+func Indent(depth int) string {
+	return strings.Repeat("  ", depth)
+}
+
+func (b BaseElement) GetTag() string {
+	return b.Tag
+}
+
+func (b BaseElement) GetAttributes() Attributes {
+	return b.Attributes
+}
+
+type ModelWriter struct {
+	Model assembly.ModelData
+	Root NestedElement
+	// Mesh directory relative to the model file
+	MeshDir string
+	// Replaces HeaderCommentStub when set
+	Provenance *Provenance
+	Options    ModelOptions
+	// PartKey of the parts whose mesh is referenced by a geom
+	meshParts map[string]bool
+	bodyNames *Namer
+	geomNames *Namer
+	meshNames *Namer
+	// One material per distinct part appearance, in order of first use
+	materials     []Element
+	materialNames map[assembly.Color]string
+	// Default classes referenced by physical materials
+	classes map[string]bool
+}
+
+// Clears what was collected by a previous MakeModel
+func (m *ModelWriter) reset() {
+	m.meshParts = make(map[string]bool)
+	m.materials = nil
+	m.materialNames = make(map[assembly.Color]string)
+	m.classes = make(map[string]bool)
+}
+
+func (n *NestedElement) AppendInline(tag string, attrs Attributes) {
+	n.Children = append(n.Children, NewInlineElement(tag, attrs))
+}
+
+func (n *NestedElement) AppendNested(tag string, attrs Attributes, children []Element) {
+	n.Children = append(n.Children, NewNestedElement(tag, attrs, children))
+}
+
+func GetDocumentName(model assembly.ModelData) string {
+	return model.DocumentInfo.GetName()
+}
+
+func NewModelWriter(model assembly.ModelData) *ModelWriter {
+	return &ModelWriter {
+		Model: model,
+		Root: NewNestedElement("mujoco", Attributes{"model": GetDocumentName(model)}, nil),
+		MeshDir: "meshes",
+		Options: DefaultModelOptions(),
+	}
+}
+
+func TraverseElements(root NestedElement, depth int) string {
+	str := Indent(depth) + root.StartTagStr()
+	if depth <= 1 {
+		str = "\n" + str
+	}
+	for _, e := range root.Children {
+		inline, ok := e.(InlineElement)
+		if ok {
+			str += Indent(depth + 1) + inline.TagStr()	
+		} else {
+			nested := e.(NestedElement)
+			str += TraverseElements(nested, depth + 1)
+		}
+	}
+	str += Indent(depth) + root.EndTagStr()
+
+	return str
+}
+
+const HeaderCommentStub = "<!--\n  Generated by Onshape MCJF Exporter.\n\n  Do not modify.\n-->\n"
+
+func (m *ModelWriter) ModelToString() string {
+	header := HeaderCommentStub
+	if m.Provenance != nil {
+		header = m.Provenance.Comment()
+	}
+	return header + TraverseElements(m.Root, 0)
+}
+/*
+<option timestep="0.005"/>
+
+<visual>
+  <map force="0.1" zfar="30"/>
+  <rgba haze="0.15 0.25 0.35 1"/>
+  <global offwidth="2560" offheight="1440" elevation="-20" azimuth="120"/>
+</visual>
+
+<asset>
+  <texture type="skybox" builtin="gradient" rgb1=".3 .5 .7" rgb2="0 0 0" width="32" height="512"/>
+  <texture name="body" type="cube" builtin="flat" mark="cross" width="128" height="128" rgb1="0.8 0.6 0.4" rgb2="0.8 0.6 0.4" markrgb="1 1 1" random="0.01"/>
+  <material name="body" texture="body" texuniform="true" rgba="0.8 0.6 .4 1"/>
+  <texture name="grid" type="2d" builtin="checker" width="512" height="512" rgb1=".1 .2 .3" rgb2=".2 .3 .4"/>
+  <material name="grid" texture="grid" texrepeat="1 1" texuniform="true" reflectance=".2"/>
+</asset>
+*/
+
+func (m *ModelWriter) ExportSTL() {
+}
+
+func (m *ModelWriter) optionAttributes() Attributes {
+	physics := m.Options.Physics
+	attrs := Attributes{"timestep": FloatStr(physics.Timestep)}
+	units := m.Options.Units
+	if physics.Gravity != "" {
+		attrs["gravity"] = physics.Gravity
+	} else if units.LengthScale() != 1 || units.Up == UP_Y {
+		attrs["gravity"] = units.GravityStr()
+	}
+	if physics.Integrator != "" {
+		attrs["integrator"] = physics.Integrator
+	}
+	return attrs
+}
+
+// Settings shared by every geom, emitted once as the default class
+func (m *ModelWriter) geomDefaults() Attributes {
+	attrs := Attributes{}
+	material := m.Options.DefaultMaterial
+	if material.Density != 0 {
+		attrs["density"] = m.Options.Units.DensityStr(material.Density)
+	}
+	if material.Friction != "" {
+		attrs["friction"] = material.Friction
+	}
+	if m.Options.Collision.Strategy == COLLISION_NONE {
+		attrs["contype"] = "0"
+		attrs["conaffinity"] = "0"
+	}
+	return attrs
+}
+
+// Parts with a geom in the model, those excluded by overrides left out.
+// Only valid after MakeModel.
+func (m *ModelWriter) MeshParts() []assembly.PartInfo {
+	parts := make([]assembly.PartInfo, 0, len(m.meshParts))
+	for _, part := range m.Model.PartInfoList {
+		if m.meshParts[PartKey(&part)] {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// Children of the top level default: the geom defaults and an empty class
+// per class used by a physical material
+func (m *ModelWriter) defaults() []Element {
+	defaults := make([]Element, 0)
+	if geom := m.geomDefaults(); len(geom) > 0 {
+		defaults = append(defaults, NewInlineElement("geom", geom))
+	}
+	classes := make([]string, 0, len(m.classes))
+	for class := range m.classes {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		defaults = append(defaults, NewInlineElement("default", Attributes{"class": class}))
+	}
+	return defaults
+}
+
+func (m *ModelWriter) MakeModel() {
+	// The bodies decide which materials, classes and meshes are needed
+	m.reset()
+	m.assignNames(m.Model.Occurrences)
+	worldbody := make([]Element, 0, len(m.Model.Occurrences))
+	for _, occ := range m.Model.Occurrences {
+		if body, ok := m.occurrenceBody(occ, occurrenceKey("", occ), m.Options.Units.RootTransform()); ok {
+			worldbody = append(worldbody, body)
+		}
+	}
+
+	m.Root.AppendInline("compiler", Attributes{"meshdir": m.MeshDir, "angle": m.Options.Units.angle()})
+	m.Root.AppendInline("option", m.optionAttributes())
+	if defaults := m.defaults(); len(defaults) > 0 {
+		m.Root.AppendNested("default", Attributes{}, defaults)
+	}
+	m.Root.AppendNested("visual", Attributes{}, []Element {
+		NewInlineElement("map", Attributes{"force": "0.1", "zfar": "30"}),
+		NewInlineElement("rgba", Attributes{"haze": "0.15 0.25 0.35 1"}),
+		NewInlineElement("global", Attributes{"offwidth": "2560", "offheight": "1440", "elevation": "-20", "azimuth": "120"}),
+	})
+	assets := []Element {
+		NewInlineElement("texture", Attributes{"type": "skybox", "builtin": "gradient", "rgb1":".3 .5 .7", "rgb2":"0 0 0", "width":"32", "height":"512"}),
+		NewInlineElement("texture", Attributes{"name":"body", "type":"cube", "builtin":"flat", "mark":"cross", "width":"128", "height":"128", "rgb1":"0.8 0.6 0.4", "rgb2":"0.8 0.6 0.4", "markrgb":"1 1 1", "random":"0.01"}),
+		NewInlineElement("material", Attributes{"name":DEFAULT_MATERIAL, "texture":"body", "texuniform":"true", "rgba": m.Options.DefaultMaterial.Rgba}),
+		NewInlineElement("texture", Attributes{"name":"grid", "type":"2d", "builtin":"checker", "width":"512", "height":"512", "rgb1":".1 .2 .3", "rgb2":".2 .3 .4"}),
+		NewInlineElement("material", Attributes{"name":"grid", "texture":"grid", "texrepeat":"1 1", "texuniform":"true", "reflectance":".2"}),
+	}
+	assets = append(assets, m.materials...)
+	m.Root.AppendNested("asset", Attributes{}, append(assets, MeshAssets(m.MeshParts(), m.meshNames)...))
+	m.Root.AppendNested("worldbody", Attributes{}, worldbody)
+}
+
+// Name of the part's mesh and STL file without the extension. Only valid
+// after MakeModel.
+func (m *ModelWriter) MeshName(part *assembly.PartInfo) string {
+	return m.meshNames.Name(PartKey(part))
+}
@@ -0,0 +1,47 @@
+package mjcf
+
+import (
+	"strings"
+	"testing"
+
+	onshapeapi "github.com/onshape-public/go-client/onshape"
+	"onshape-mcjf-exporter/assembly"
+)
+
+func TestAttributeValuesAreEscaped(t *testing.T) {
+	name := `A & B "v2" <left>`
+	class := `soft & "grippy"`
+	document := onshapeapi.NewBTDocumentInfo()
+	document.SetName(name)
+	parts := []assembly.PartInfo{{Id: "JHD", Name: "Pad", Material: "Rubber"}}
+	writer := NewModelWriter(assembly.ModelData{
+		DocumentInfo: document,
+		PartInfoList: parts,
+		Occurrences: []assembly.Occurrence{
+			&assembly.PartOccurrence{BaseOccurrence: assembly.BaseOccurrence{Transform: assembly.IdentityTransform(), Id: "i1", Path: []string{"i1"}, Name: "Pad <1>"}, Part: &parts[0]},
+		},
+	})
+	writer.Options.Materials = []MaterialRule{{Match: "Rubber", PhysicalMaterial: PhysicalMaterial{Class: class}}}
+	writer.MakeModel()
+
+	root, err := Parse(strings.NewReader(writer.ModelToString()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := root.Attr("model"); got != name {
+		t.Errorf("model %q, want %q", got, name)
+	}
+	classes := 0
+	root.Walk(func(_ string, node *Node) {
+		if got, ok := node.Attr("class"); ok {
+			classes++
+			if got != class {
+				t.Errorf("%s class %q, want %q", node.Tag(), got, class)
+			}
+		}
+	})
+	// The default class and the geom referencing it
+	if classes != 2 {
+		t.Errorf("%d classes, want 2", classes)
+	}
+}
@@ -0,0 +1,234 @@
+package onshape
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	onshapeapi "github.com/onshape-public/go-client/onshape"
+)
+
+func (c *Client) GetAssemblyDefinitionInfo(path ElementPath) (*onshapeapi.BTAssemblyDefinitionInfo, error) {
+	key := MetadataCacheKey("assembly", path.DocumentId, path.WVM, path.WVMId, path.ElementId, path.Configuration)
+
+	var cached onshapeapi.BTAssemblyDefinitionInfo
+	if ok, err := c.loadCached(key, path.WVM, &cached); ok || err != nil {
+		return &cached, err
+	}
+
+	request := c.API.AssemblyApi.
+		GetAssemblyDefinition(
+			c.Ctx,
+			path.DocumentId,
+			path.WVM,
+			path.WVMId,
+			path.ElementId).
+		IncludeMateConnectors(true).IncludeMateFeatures(true)
+	if path.Configuration != "" {
+		request = request.Configuration(path.Configuration)
+	}
+	assemblyDef, resp, err := request.Execute()
+
+	if err := checkRequest("get assembly definition", resp, err); err != nil {
+		return nil, err
+	}
+
+	c.storeCached(key, assemblyDef)
+	return assemblyDef, nil
+}
+
+func (c *Client) GetDocumentInfo(did string) (*onshapeapi.BTDocumentInfo, error) {
+	key := MetadataCacheKey("document", did)
+
+	// Document info is not versioned, so it is only reused when offline
+	var cached onshapeapi.BTDocumentInfo
+	if ok, err := c.loadCached(key, "", &cached); ok || err != nil {
+		return &cached, err
+	}
+
+	docInfo, resp, err := c.API.DocumentApi.GetDocument(c.Ctx, did).Execute()
+
+	if err := checkRequest("get document", resp, err); err != nil {
+		return nil, err
+	}
+
+	c.storeCached(key, docInfo)
+	return docInfo, nil
+}
+
+func (c *Client) GetPartsInfo(path ElementPath) ([]onshapeapi.BTPartMetadataInfo, error) {
+	key := MetadataCacheKey("parts", path.DocumentId, path.WVM, path.WVMId, path.ElementId, path.Configuration)
+
+	var cached []onshapeapi.BTPartMetadataInfo
+	if ok, err := c.loadCached(key, path.WVM, &cached); ok || err != nil {
+		return cached, err
+	}
+
+	request := c.API.PartApi.GetPartsWMVE(c.Ctx, path.DocumentId, path.WVM, path.WVMId, path.ElementId)
+	if path.Configuration != "" {
+		request = request.Configuration(path.Configuration)
+	}
+	partsInfo, resp, err := request.Execute()
+
+	if err := checkRequest("get parts", resp, err); err != nil {
+		return nil, err
+	}
+
+	c.storeCached(key, partsInfo)
+	return partsInfo, nil
+}
+
+func (c *Client) GetElementInfo(path ElementPath) (onshapeapi.BTDocumentElementInfo, error) {
+	key := MetadataCacheKey("element", path.DocumentId, path.WVM, path.WVMId, path.ElementId)
+
+	var cached onshapeapi.BTDocumentElementInfo
+	if ok, err := c.loadCached(key, path.WVM, &cached); ok || err != nil {
+		return cached, err
+	}
+
+	elements, resp, err := c.API.DocumentApi.GetElementsInDocument(c.Ctx, path.DocumentId, path.WVM, path.WVMId).ElementId(path.ElementId).Execute()
+
+	if err := checkRequest("get element", resp, err); err != nil {
+		return onshapeapi.BTDocumentElementInfo{}, err
+	}
+	if len(elements) == 0 {
+		return onshapeapi.BTDocumentElementInfo{}, fmt.Errorf("element %s in document %s: %w", path.ElementId, path.DocumentId, ErrNotFound)
+	}
+
+	c.storeCached(key, elements[0])
+	return elements[0], nil
+}
+
+// Mass properties of one part in kilograms and meters, as computed from its
+// material in Onshape
+func (c *Client) GetMassProperties(path ElementPath, partId string) (*onshapeapi.BTMassPropertiesInfo, error) {
+	key := MetadataCacheKey("massproperties", path.DocumentId, path.WVM, path.WVMId, path.ElementId, path.Configuration, partId)
+
+	var cached onshapeapi.BTMassPropertiesInfo
+	if ok, err := c.loadCached(key, path.WVM, &cached); ok || err != nil {
+		return &cached, err
+	}
+
+	request := c.API.PartApi.GetMassProperties(c.Ctx, path.DocumentId, path.WVM, path.WVMId, path.ElementId, partId)
+	if path.Configuration != "" {
+		request = request.Configuration(path.Configuration)
+	}
+	bulk, resp, err := request.Execute()
+
+	if err := checkRequest("get mass properties", resp, err); err != nil {
+		return nil, err
+	}
+
+	body, ok := bulk.GetBodies()[partId]
+	if !ok {
+		return nil, fmt.Errorf("mass properties of part %s: %w", partId, ErrNotFound)
+	}
+
+	c.storeCached(key, body)
+	return &body, nil
+}
+
+// Exports a part as STL. Onshape answers with a redirect to a different
+// server, which is fetched separately with the same credentials.
+func (c *Client) ExportStl(path ElementPath, partId string, units string, mode string) ([]byte, error) {
+	request := c.API.PartApi.ExportStl(c.Ctx, path.DocumentId, path.WVM, path.WVMId, path.ElementId, partId).Mode(mode).Units(units)
+	if path.Configuration != "" {
+		request = request.Configuration(path.Configuration)
+	}
+	_, resp, err := request.Execute()
+
+	location := ""
+	if resp != nil && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		location = resp.Header.Get("Location")
+	}
+	if location == "" {
+		if err := checkRequest("export stl", resp, err); err != nil {
+			return nil, err
+		}
+		return nil, &APIError{Op: "export stl", Err: fmt.Errorf("no redirect for part %s", partId)}
+	}
+
+	return c.download(location)
+}
+
+func (c *Client) download(url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(c.Ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make stl request: %w", err)
+	}
+	req.Header.Add("accept", "application/octet-stream")
+	authorization, err := c.authorization()
+	if err != nil {
+		return nil, err
+	}
+	if authorization != "" {
+		req.Header.Add("Authorization", authorization)
+	}
+	resp, err := c.API.GetConfig().HTTPClient.Do(req)
+	// Failed statuses come with a body too
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err := checkRequest("download stl", resp, err); err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stl response body: %w", err)
+	}
+
+	return body, nil
+}
+
+// Microversion a workspace or version currently points at
+func (c *Client) GetCurrentMicroversion(path ElementPath) (string, error) {
+	key := MetadataCacheKey("microversion", path.DocumentId, path.WVM, path.WVMId)
+
+	// Workspaces move on, so this is only reused when offline
+	var cached onshapeapi.BTMicroversionInfo
+	if ok, err := c.loadCached(key, "", &cached); ok || err != nil {
+		return cached.GetMicroversion(), err
+	}
+
+	info, resp, err := c.API.DocumentApi.GetCurrentMicroversion(c.Ctx, path.DocumentId, path.WVM, path.WVMId).Execute()
+
+	if err := checkRequest("get current microversion", resp, err); err != nil {
+		return "", err
+	}
+
+	c.storeCached(key, info)
+	return info.GetMicroversion(), nil
+}
+
+func (c *Client) GetDocumentVersions(did string) ([]onshapeapi.BTVersionInfo, error) {
+	key := MetadataCacheKey("versions", did)
+
+	var cached []onshapeapi.BTVersionInfo
+	if ok, err := c.loadCached(key, "", &cached); ok || err != nil {
+		return cached, err
+	}
+
+	versions, resp, err := c.API.DocumentApi.GetDocumentVersions(c.Ctx, did).Execute()
+
+	if err := checkRequest("get document versions", resp, err); err != nil {
+		return nil, err
+	}
+
+	c.storeCached(key, versions)
+	return versions, nil
+}
+
+// Version of a document by name or id
+func (c *Client) FindVersion(did string, nameOrId string) (*onshapeapi.BTVersionInfo, error) {
+	versions, err := c.GetDocumentVersions(did)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, version := range versions {
+		if version.GetName() == nameOrId || version.GetId() == nameOrId {
+			return &versions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("version %q of document %s: %w", nameOrId, did, ErrNotFound)
+}
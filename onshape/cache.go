@@ -0,0 +1,65 @@
+package onshape
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"onshape-mcjf-exporter/internal/fileutil"
+)
+
+const CACHE_DIR_NAME = "onshape-mjcf"
+
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, CACHE_DIR_NAME)
+}
+
+// Cache of Onshape API metadata responses, stored as the JSON the API returned.
+// Responses addressed by microversion are reused on every run, everything
+// else is only read back when running offline.
+type MetadataCache struct {
+	Dir string
+}
+
+func NewMetadataCache(dir string) *MetadataCache {
+	if dir == "" {
+		dir = DefaultCacheDir()
+	}
+	return &MetadataCache{Dir: filepath.Join(dir, "metadata")}
+}
+
+func MetadataCacheKey(kind string, ids ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(ids, "/")))
+	return kind + "-" + hex.EncodeToString(sum[:])
+}
+
+func (c *MetadataCache) path(key string) string {
+	return filepath.Join(c.Dir, key + ".json")
+}
+
+func (c *MetadataCache) Load(key string, v any) bool {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+func (c *MetadataCache) Store(key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteFileAtomic(c.path(key), data); err != nil {
+		return fmt.Errorf("failed to store cached metadata: %w", err)
+	}
+	return nil
+}
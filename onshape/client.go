@@ -0,0 +1,141 @@
+// Package onshape wraps the Onshape REST client with authentication,
+// response caching and the handful of API calls the exporter needs.
+package onshape
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+
+	onshapeapi "github.com/onshape-public/go-client/onshape"
+	"golang.org/x/oauth2"
+)
+
+type Credentials struct {
+	BaseUrl   string `json:"base_url"`
+	SecretKey string `json:"secret_key"`
+	AccessKey string `json:"access_key"`
+	// How the keys authenticate requests: AUTH_BASIC (default) or AUTH_HMAC
+	Auth string `json:"auth,omitempty"`
+}
+
+type ClientOptions struct {
+	Credentials Credentials
+	// Authenticates as an OAuth user instead of with the API keys when set
+	TokenSource oauth2.TokenSource
+	// Scheme and host of the Onshape server, e.g. https://cad.onshape.com/
+	ServerURL string
+	CacheDir  string
+	// Serve everything from the cache and never touch the network
+	Offline bool
+	// Optional transport, e.g. a FixtureTransport
+	Transport http.RoundTripper
+	Debug     bool
+}
+
+type Client struct {
+	API         *onshapeapi.APIClient
+	Ctx         context.Context
+	Credentials Credentials
+	Cache       *MetadataCache
+	Offline     bool
+	TokenSource oauth2.TokenSource
+}
+
+func MakeAuthorizationHeader(accessKey string, secretKey string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(accessKey + ":" + secretKey))
+}
+
+func NewClient(ctx context.Context, options ClientOptions) *Client {
+	config := onshapeapi.NewConfiguration()
+
+	transport := options.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	// The API client's own debug mode would log the authorization header
+	if options.Debug {
+		transport = &DebugTransport{Next: transport}
+	}
+	signed := options.TokenSource == nil && options.Credentials.Auth == AUTH_HMAC
+	if signed {
+		transport = &SigningTransport{
+			AccessKey: options.Credentials.AccessKey,
+			SecretKey: options.Credentials.SecretKey,
+			Next:      transport,
+		}
+	}
+	config.HTTPClient = &http.Client{
+		// Exports redirect to a different server, which is followed by hand
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Transport: transport,
+	}
+	if options.ServerURL != "" {
+		config.Servers = onshapeapi.ServerConfigurations{
+			{URL: options.ServerURL + "api/v6"},
+		}
+	}
+
+	authCtx := ctx
+	if options.TokenSource != nil {
+		authCtx = context.WithValue(ctx, onshapeapi.ContextOAuth2, options.TokenSource)
+	} else if !signed {
+		authCtx = context.WithValue(
+			ctx,
+			onshapeapi.ContextBasicAuth,
+			onshapeapi.BasicAuth{
+				UserName: options.Credentials.AccessKey,
+				Password: options.Credentials.SecretKey,
+			},
+		)
+	}
+
+	return &Client{
+		API:         onshapeapi.NewAPIClient(config),
+		Ctx:         authCtx,
+		Credentials: options.Credentials,
+		Cache:       NewMetadataCache(options.CacheDir),
+		Offline:     options.Offline,
+		TokenSource: options.TokenSource,
+	}
+}
+
+// Authorization header value for requests made outside the API client, empty
+// when the transport signs requests itself
+func (c *Client) authorization() (string, error) {
+	if c.TokenSource == nil && c.Credentials.Auth == AUTH_HMAC {
+		return "", nil
+	}
+	if c.TokenSource == nil {
+		return MakeAuthorizationHeader(c.Credentials.AccessKey, c.Credentials.SecretKey), nil
+	}
+	token, err := c.TokenSource.Token()
+	if err != nil {
+		return "", err
+	}
+	return token.Type() + " " + token.AccessToken, nil
+}
+
+// Only microversion responses are immutable, anything else is read back from the cache when offline
+func (c *Client) loadCached(key string, wvm string, v any) (bool, error) {
+	if wvm != "m" && !c.Offline {
+		return false, nil
+	}
+	if c.Cache.Load(key, v) {
+		return true, nil
+	}
+	if c.Offline {
+		return false, fmt.Errorf("cached response %s: %w", key, ErrNotCached)
+	}
+	return false, nil
+}
+
+func (c *Client) storeCached(key string, v any) {
+	if err := c.Cache.Store(key, v); err != nil {
+		log.Printf("warning: failed to cache %s -- %v", key, err)
+	}
+}
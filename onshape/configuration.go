@@ -0,0 +1,175 @@
+package onshape
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	onshapeapi "github.com/onshape-public/go-client/onshape"
+)
+
+// Configuration input of a configurable element
+type ConfigurationParameter struct {
+	Id   string
+	Name string
+	// Option ids and names, only set for lists
+	OptionIds   []string
+	OptionNames []string
+}
+
+func (c *Client) GetConfigurationInfo(path ElementPath) (*onshapeapi.BTConfigurationResponse2019, error) {
+	key := MetadataCacheKey("configuration", path.DocumentId, path.WVM, path.WVMId, path.ElementId)
+
+	var cached onshapeapi.BTConfigurationResponse2019
+	if ok, err := c.loadCached(key, path.WVM, &cached); ok || err != nil {
+		return &cached, err
+	}
+
+	info, resp, err := c.API.ElementApi.GetConfiguration(c.Ctx, path.DocumentId, path.WVM, path.WVMId, path.ElementId).Execute()
+
+	if err := checkRequest("get configuration", resp, err); err != nil {
+		return nil, err
+	}
+
+	c.storeCached(key, info)
+	return info, nil
+}
+
+// Configuration inputs of the element at path, empty if it is not configurable
+func (c *Client) GetConfigurationParameters(path ElementPath) ([]ConfigurationParameter, error) {
+	info, err := c.GetConfigurationInfo(path)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := make([]ConfigurationParameter, 0)
+	for _, p := range info.GetConfigurationParameters() {
+		parameter := ConfigurationParameter{Id: p.GetParameterId(), Name: p.GetParameterName()}
+		if enum, ok := p.GetActualInstance().(*onshapeapi.BTMConfigurationParameterEnum105); ok {
+			for _, option := range enum.GetOptions() {
+				parameter.OptionIds = append(parameter.OptionIds, option.GetOption())
+				parameter.OptionNames = append(parameter.OptionNames, option.GetOptionName())
+			}
+		}
+		parameters = append(parameters, parameter)
+	}
+	return parameters, nil
+}
+
+// Parameter with the given name or id
+func FindConfigurationParameter(parameters []ConfigurationParameter, nameOrId string) (*ConfigurationParameter, error) {
+	for i, p := range parameters {
+		if p.Name == nameOrId || p.Id == nameOrId {
+			return &parameters[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no configuration parameter %q", ErrInvalidConfiguration, nameOrId)
+}
+
+// Value as the API expects it, i.e. the option id for lists
+func (p *ConfigurationParameter) Encode(value string) (string, error) {
+	if len(p.OptionIds) == 0 {
+		return value, nil
+	}
+	for i := range p.OptionIds {
+		if p.OptionIds[i] == value || p.OptionNames[i] == value {
+			return p.OptionIds[i], nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q is not an option of %s, expected one of %s", ErrInvalidConfiguration, value, p.Name, strings.Join(p.OptionNames, ", "))
+}
+
+// Splits an encoded configuration such as "List_abc=Left;Length=10 mm"
+func ParseConfiguration(encoded string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(encoded, ";") {
+		if pair == "" {
+			continue
+		}
+		id, value, ok := strings.Cut(pair, "=")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("%w: expected parameter=value, got %q", ErrInvalidConfiguration, pair)
+		}
+		values[id] = value
+	}
+	return values, nil
+}
+
+// Encodes parameter values keyed by parameter id, in a stable order
+func EncodeConfiguration(values map[string]string) string {
+	ids := make([]string, 0, len(values))
+	for id := range values {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	pairs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		pairs = append(pairs, id + "=" + values[id])
+	}
+	return strings.Join(pairs, ";")
+}
+
+// Overrides the configuration of path with values given by parameter name or
+// id and option name or id, as written in a config file. Later layers take
+// precedence over earlier ones, whether a parameter is given by name or id.
+func (c *Client) Configure(path ElementPath, layers ...map[string]string) (ElementPath, error) {
+	empty := true
+	for _, values := range layers {
+		empty = empty && len(values) == 0
+	}
+	if empty {
+		return path, nil
+	}
+
+	parameters, err := c.GetConfigurationParameters(path)
+	if err != nil {
+		return path, err
+	}
+	encoded, err := ParseConfiguration(path.Configuration)
+	if err != nil {
+		return path, err
+	}
+
+	for _, values := range layers {
+		resolved, err := resolveConfiguration(parameters, values)
+		if err != nil {
+			return path, err
+		}
+		for id, value := range resolved {
+			encoded[id] = value
+		}
+	}
+
+	path.Configuration = EncodeConfiguration(encoded)
+	return path, nil
+}
+
+// Encoded values of one layer keyed by parameter id. A parameter given both
+// by name and by id must have the same value in both.
+func resolveConfiguration(parameters []ConfigurationParameter, values map[string]string) (map[string]string, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resolved := make(map[string]string, len(values))
+	given := make(map[string]string, len(values))
+	for _, name := range names {
+		parameter, err := FindConfigurationParameter(parameters, name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := parameter.Encode(values[name])
+		if err != nil {
+			return nil, err
+		}
+		if previous, ok := resolved[parameter.Id]; ok && previous != value {
+			return nil, fmt.Errorf("%w: %s is given as both %s=%s and %s=%s", ErrInvalidConfiguration, parameter.Name, given[parameter.Id], values[given[parameter.Id]], name, values[name])
+		}
+		resolved[parameter.Id] = value
+		given[parameter.Id] = name
+	}
+	return resolved, nil
+}
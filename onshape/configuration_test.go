@@ -0,0 +1,73 @@
+package onshape
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"onshape-mcjf-exporter/onshapetest"
+)
+
+// Client of a fake server with element e1 configurable by the Side list
+func newConfiguredClient(t *testing.T) (*Client, ElementPath) {
+	t.Helper()
+	s := onshapetest.NewServer()
+	t.Cleanup(s.Close)
+	s.AddConfigurationList("d1", "w", "w1", "e1", "List_side", "Side", []string{"opt_left", "opt_right"}, []string{"Left", "Right"})
+	s.AddConfigurationList("d1", "w", "w1", "e1", "List_grip", "Gripper", []string{"opt_parallel", "opt_suction"}, []string{"Parallel", "Suction"})
+
+	client := NewClient(context.Background(), ClientOptions{ServerURL: s.URL + "/", CacheDir: t.TempDir()})
+	return client, ElementPath{DocumentId: "d1", WVM: "w", WVMId: "w1", ElementId: "e1"}
+}
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		layers []map[string]string
+		want   string
+	}{
+		{"by name", "", []map[string]string{{"Side": "Left"}}, "List_side=opt_left"},
+		{"by id", "", []map[string]string{{"List_side": "opt_right"}}, "List_side=opt_right"},
+		{"over the url", "List_grip=opt_suction;List_side=opt_left", []map[string]string{{"Side": "Right"}}, "List_grip=opt_suction;List_side=opt_right"},
+		{"name overridden by id", "", []map[string]string{{"Side": "Left"}, {"List_side": "Right"}}, "List_side=opt_right"},
+		{"id overridden by name", "", []map[string]string{{"List_side": "Left"}, {"Side": "Right"}}, "List_side=opt_right"},
+		{"name and id agreeing", "", []map[string]string{{"Side": "Left", "List_side": "opt_left"}}, "List_side=opt_left"},
+		{"nothing to configure", "List_side=opt_left", []map[string]string{nil, {}}, "List_side=opt_left"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, path := newConfiguredClient(t)
+			path.Configuration = test.url
+			// Map order must not matter
+			for i := 0; i < 10; i++ {
+				configured, err := client.Configure(path, test.layers...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if configured.Configuration != test.want {
+					t.Fatalf("configuration %q, want %q", configured.Configuration, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigureRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]string
+	}{
+		{"unknown option", map[string]string{"Side": "Middle"}},
+		{"unknown parameter", map[string]string{"Color": "Red"}},
+		{"name and id disagreeing", map[string]string{"Side": "Left", "List_side": "Right"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, path := newConfiguredClient(t)
+			if _, err := client.Configure(path, test.values); !errors.Is(err, ErrInvalidConfiguration) {
+				t.Errorf("got %v, want ErrInvalidConfiguration", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package onshape
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	ENV_ACCESS_KEY = "ONSHAPE_ACCESS_KEY"
+	ENV_SECRET_KEY = "ONSHAPE_SECRET_KEY"
+	ENV_BASE_URL   = "ONSHAPE_BASE_URL"
+
+	CREDENTIALS_FILE_NAME = "credentials.json"
+	// Service name of the API keys in the OS keyring
+	KEYRING_SERVICE = "onshape-mjcf"
+)
+
+// Source of API keys. Providers return only the fields they know and leave
+// the others empty.
+type SecretProvider interface {
+	Name() string
+	Credentials() (Credentials, error)
+}
+
+// Reads ONSHAPE_ACCESS_KEY, ONSHAPE_SECRET_KEY and ONSHAPE_BASE_URL
+type EnvProvider struct{}
+
+func (EnvProvider) Name() string {
+	return "environment"
+}
+
+func (EnvProvider) Credentials() (Credentials, error) {
+	return Credentials{
+		BaseUrl:   os.Getenv(ENV_BASE_URL),
+		AccessKey: os.Getenv(ENV_ACCESS_KEY),
+		SecretKey: os.Getenv(ENV_SECRET_KEY),
+	}, nil
+}
+
+// Credentials given directly, e.g. from the exporter config file
+type StaticProvider struct {
+	Source string
+	Value  Credentials
+}
+
+func (p StaticProvider) Name() string {
+	return p.Source
+}
+
+func (p StaticProvider) Credentials() (Credentials, error) {
+	return p.Value, nil
+}
+
+// JSON file with the same fields as onshape_client in the exporter config
+type FileProvider struct {
+	Path string
+}
+
+// credentials.json in the user config directory, e.g.
+// ~/.config/onshape-mjcf/credentials.json on Linux
+func DefaultCredentialsFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, CACHE_DIR_NAME, CREDENTIALS_FILE_NAME)
+}
+
+func (p FileProvider) Name() string {
+	return p.Path
+}
+
+func (p FileProvider) Credentials() (Credentials, error) {
+	if p.Path == "" {
+		return Credentials{}, nil
+	}
+	contents, err := os.ReadFile(p.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Credentials{}, nil
+	}
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to read credentials: %w", err)
+	}
+
+	var credentials Credentials
+	if err := json.Unmarshal(contents, &credentials); err != nil {
+		return Credentials{}, fmt.Errorf("failed to parse credentials %s: %w", p.Path, err)
+	}
+	return credentials, nil
+}
+
+// API keys stored in the OS keyring under KEYRING_SERVICE with the accounts
+// access_key and secret_key. Uses secret-tool on Linux and security on macOS,
+// and finds nothing when neither is installed.
+type KeyringProvider struct {
+	Service string
+}
+
+func (p KeyringProvider) Name() string {
+	return "keyring"
+}
+
+func (p KeyringProvider) lookup(account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", p.Service, "-a", account, "-w")
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", p.Service, "account", account)
+	default:
+		return "", nil
+	}
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.Is(err, exec.ErrNotFound) || errors.As(err, &exitErr) {
+			// Tool missing or no such entry
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to query the keyring: %w", err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (p KeyringProvider) Credentials() (Credentials, error) {
+	accessKey, err := p.lookup("access_key")
+	if err != nil || accessKey == "" {
+		return Credentials{}, err
+	}
+	secretKey, err := p.lookup("secret_key")
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{AccessKey: accessKey, SecretKey: secretKey}, nil
+}
+
+// Providers in order of precedence: environment, the exporter config file,
+// the user credentials file and the OS keyring
+func DefaultProviders(config Credentials) []SecretProvider {
+	return []SecretProvider{
+		EnvProvider{},
+		StaticProvider{Source: "config file", Value: config},
+		FileProvider{Path: DefaultCredentialsFile()},
+		KeyringProvider{Service: KEYRING_SERVICE},
+	}
+}
+
+// Key pair of the first provider that has an access key. Later providers are
+// not consulted, so a keyring is never unlocked when the environment already
+// holds the keys, and keys from different places are never mixed.
+func ResolveCredentials(providers ...SecretProvider) (Credentials, error) {
+	for _, provider := range providers {
+		credentials, err := provider.Credentials()
+		if err != nil {
+			return Credentials{}, fmt.Errorf("%s: %w", provider.Name(), err)
+		}
+		if credentials.AccessKey != "" {
+			return Credentials{AccessKey: credentials.AccessKey, SecretKey: credentials.SecretKey}, nil
+		}
+	}
+	return Credentials{}, nil
+}
+
+// Server or document url from ONSHAPE_BASE_URL, else from the config file,
+// else from the user credentials file
+func ResolveBaseURL(config string) (string, error) {
+	if base := os.Getenv(ENV_BASE_URL); base != "" {
+		return base, nil
+	}
+	if config != "" {
+		return config, nil
+	}
+	credentials, err := FileProvider{Path: DefaultCredentialsFile()}.Credentials()
+	return credentials.BaseUrl, err
+}
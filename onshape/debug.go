@@ -0,0 +1,81 @@
+package onshape
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+const REDACTED = "REDACTED"
+
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// Query parameters of signed download urls that grant access on their own
+var redactedParams = []string{"signature", "token", "key", "credential", "secret"}
+
+// Logs every request and response like the API client's debug mode, with
+// credentials replaced by REDACTED
+type DebugTransport struct {
+	Next http.RoundTripper
+}
+
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	logged := req.Clone(req.Context())
+	logged.URL = RedactURL(req.URL)
+	redactHeader(logged.Header)
+	// The body is left out, requests made by the exporter never carry one
+	if dump, err := httputil.DumpRequestOut(logged, false); err == nil {
+		log.Printf("\n%s\n", dump)
+	}
+
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	json := strings.Contains(resp.Header.Get("Content-Type"), "json")
+	header := resp.Header
+	resp.Header = header.Clone()
+	redactHeader(resp.Header)
+	if location := resp.Header.Get("Location"); location != "" {
+		if u, err := url.Parse(location); err == nil {
+			resp.Header.Set("Location", RedactURL(u).String())
+		}
+	}
+	if dump, err := httputil.DumpResponse(resp, json); err == nil {
+		log.Printf("\n%s\n", dump)
+	}
+	resp.Header = header
+	return resp, nil
+}
+
+func redactHeader(header http.Header) {
+	for _, name := range redactedHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, REDACTED)
+		}
+	}
+}
+
+// Copy of u with the values of credential-like query parameters redacted
+func RedactURL(u *url.URL) *url.URL {
+	redacted := *u
+	query := u.Query()
+	changed := false
+	for name := range query {
+		lower := strings.ToLower(name)
+		for _, secret := range redactedParams {
+			if strings.Contains(lower, secret) {
+				query.Set(name, REDACTED)
+				changed = true
+				break
+			}
+		}
+	}
+	if changed {
+		redacted.RawQuery = query.Encode()
+	}
+	return &redacted
+}
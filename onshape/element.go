@@ -0,0 +1,146 @@
+package onshape
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const DEFAULT_SERVER_URL = "https://cad.onshape.com/"
+
+// Document/WVM/Element address of an Onshape tab
+type ElementPath struct {
+	DocumentId string
+	WVM        string
+	WVMId      string
+	ElementId  string
+	// Encoded configuration such as "List_abc=Left;Length=10 mm", empty for
+	// the default configuration
+	Configuration string
+}
+
+type Element struct {
+	ServerURL string
+	ElementPath
+}
+
+func isId(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func (p ElementPath) Validate() error {
+	if p.WVM != "w" && p.WVM != "v" && p.WVM != "m" {
+		return fmt.Errorf("%w: %q is not one of w (workspace), v (version) or m (microversion)", ErrMalformedURL, p.WVM)
+	}
+	ids := []struct {
+		name string
+		id   string
+	}{
+		{"document id", p.DocumentId},
+		{"workspace/version/microversion id", p.WVMId},
+		{"element id", p.ElementId},
+	}
+	for _, id := range ids {
+		if !isId(id.id) {
+			return fmt.Errorf("%w: invalid %s %q", ErrMalformedURL, id.name, id.id)
+		}
+	}
+	return nil
+}
+
+// Normalizes a server address, adding https when the scheme is missing
+func ParseServerURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedURL, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%w: missing server in %q", ErrMalformedURL, raw)
+	}
+	return u, nil
+}
+
+// True for urls naming only a server, such as https://cad.onshape.com
+func IsServerURL(raw string) bool {
+	u, err := ParseServerURL(raw)
+	return err == nil && strings.Trim(u.Path, "/") == ""
+}
+
+// Element from ids given separately, e.g. as command line flags
+func NewElement(serverURL string, did string, wvm string, wvmid string, eid string) (*Element, error) {
+	if serverURL == "" {
+		serverURL = DEFAULT_SERVER_URL
+	}
+	u, err := ParseServerURL(serverURL)
+	if err != nil {
+		return nil, err
+	}
+
+	path := ElementPath{DocumentId: did, WVM: wvm, WVMId: wvmid, ElementId: eid}
+	if err := path.Validate(); err != nil {
+		return nil, err
+	}
+	return &Element{ServerURL: u.Scheme + "://" + u.Host + "/", ElementPath: path}, nil
+}
+
+// Parses a url as copied from the browser address bar. Accepts any server,
+// trailing path segments, API style /api/v6/documents/d/... paths and a
+// configuration query parameter.
+func ElementFromURL(raw string) (*Element, error) {
+	u, err := ParseServerURL(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]string, 0)
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+
+	if len(segments) > 0 && segments[0] == "api" {
+		segments = segments[1:]
+		if len(segments) > 0 && strings.HasPrefix(segments[0], "v") {
+			segments = segments[1:]
+		}
+	}
+	if len(segments) == 0 || segments[0] != "documents" {
+		return nil, fmt.Errorf("%w: expected a path starting with /documents/, got %q", ErrMalformedURL, u.Path)
+	}
+	segments = segments[1:]
+	if len(segments) > 0 && segments[0] == "d" {
+		segments = segments[1:]
+	}
+	if len(segments) < 5 || segments[3] != "e" {
+		return nil, fmt.Errorf("%w: expected /documents/{did}/{w|v|m}/{id}/e/{eid}, got %q", ErrMalformedURL, u.Path)
+	}
+
+	element, err := NewElement(u.Scheme + "://" + u.Host + "/", segments[0], segments[1], segments[2], segments[4])
+	if err != nil {
+		return nil, err
+	}
+	element.Configuration = u.Query().Get("configuration")
+	return element, nil
+}
+
+// Browser url of the element
+func (e *Element) URL() string {
+	u := e.ServerURL + "documents/" + e.DocumentId + "/" + e.WVM + "/" + e.WVMId + "/e/" + e.ElementId
+	if e.Configuration != "" {
+		u += "?configuration=" + url.QueryEscape(e.Configuration)
+	}
+	return u
+}
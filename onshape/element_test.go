@@ -0,0 +1,74 @@
+package onshape
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestElementFromURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want Element
+	}{
+		{"workspace", "https://cad.onshape.com/documents/d1/w/w1/e/e1", Element{"https://cad.onshape.com/", ElementPath{"d1", "w", "w1", "e1", ""}}},
+		{"version", "https://cad.onshape.com/documents/d1/v/v1/e/e1", Element{"https://cad.onshape.com/", ElementPath{"d1", "v", "v1", "e1", ""}}},
+		{"microversion", "https://cad.onshape.com/documents/d1/m/m1/e/e1", Element{"https://cad.onshape.com/", ElementPath{"d1", "m", "m1", "e1", ""}}},
+		{"trailing segments", "https://cad.onshape.com/documents/d1/w/w1/e/e1/view/3/", Element{"https://cad.onshape.com/", ElementPath{"d1", "w", "w1", "e1", ""}}},
+		{"api path", "https://cad.onshape.com/api/v6/documents/d/d1/w/w1/e/e1", Element{"https://cad.onshape.com/", ElementPath{"d1", "w", "w1", "e1", ""}}},
+		{"api path without version", "https://cad.onshape.com/api/documents/d/d1/m/m1/e/e1", Element{"https://cad.onshape.com/", ElementPath{"d1", "m", "m1", "e1", ""}}},
+		{"configuration", "https://cad.onshape.com/documents/d1/w/w1/e/e1?configuration=List_side%3DLeft%3BLength%3D10+mm", Element{"https://cad.onshape.com/", ElementPath{"d1", "w", "w1", "e1", "List_side=Left;Length=10 mm"}}},
+		{"enterprise host", "https://acme.onshape.com/documents/d1/w/w1/e/e1", Element{"https://acme.onshape.com/", ElementPath{"d1", "w", "w1", "e1", ""}}},
+		{"host with port", "http://localhost:8080/documents/d1/w/w1/e/e1", Element{"http://localhost:8080/", ElementPath{"d1", "w", "w1", "e1", ""}}},
+		{"without scheme", "cad.onshape.com/documents/d1/w/w1/e/e1", Element{"https://cad.onshape.com/", ElementPath{"d1", "w", "w1", "e1", ""}}},
+		{"surrounding space", "  https://cad.onshape.com/documents/d1/w/w1/e/e1\n", Element{"https://cad.onshape.com/", ElementPath{"d1", "w", "w1", "e1", ""}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			element, err := ElementFromURL(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *element != test.want {
+				t.Errorf("got %+v, want %+v", *element, test.want)
+			}
+		})
+	}
+}
+
+func TestElementFromURLRejectsMalformedURLs(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"empty", ""},
+		{"server only", "https://cad.onshape.com"},
+		{"not a document", "https://cad.onshape.com/help/documents/d1/w/w1/e/e1"},
+		{"missing element", "https://cad.onshape.com/documents/d1/w/w1"},
+		{"missing e segment", "https://cad.onshape.com/documents/d1/w/w1/x/e1"},
+		{"unknown reference", "https://cad.onshape.com/documents/d1/x/w1/e/e1"},
+		{"invalid id", "https://cad.onshape.com/documents/d-1/w/w1/e/e1"},
+		{"invalid url", "https://cad.onshape.com/documents/%zz"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if element, err := ElementFromURL(test.url); !errors.Is(err, ErrMalformedURL) {
+				t.Errorf("got %+v, %v, want ErrMalformedURL", element, err)
+			}
+		})
+	}
+}
+
+func TestNewElementFromBareIds(t *testing.T) {
+	element, err := NewElement("acme.onshape.com", "d1", "v", "v1", "e1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Element{"https://acme.onshape.com/", ElementPath{"d1", "v", "v1", "e1", ""}}
+	if *element != want {
+		t.Errorf("got %+v, want %+v", *element, want)
+	}
+	if _, err := NewElement("", "d1", "w", "", "e1"); !errors.Is(err, ErrMalformedURL) {
+		t.Errorf("got %v for a missing workspace id, want ErrMalformedURL", err)
+	}
+}
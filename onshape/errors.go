@@ -0,0 +1,63 @@
+package onshape
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrAuth                 = errors.New("onshape rejected the credentials")
+	ErrNotFound             = errors.New("not found")
+	ErrRateLimited          = errors.New("onshape rate limit exceeded")
+	ErrMalformedURL         = errors.New("malformed onshape url")
+	ErrNotCached            = errors.New("not available in the offline cache")
+	ErrInvalidConfiguration = errors.New("invalid configuration")
+)
+
+// Failed Onshape API call. Matches ErrAuth, ErrNotFound or ErrRateLimited
+// with errors.Is depending on the response status.
+type APIError struct {
+	Op         string
+	StatusCode int
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s: status %d: %v", e.Op, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Op, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrAuth:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// Wraps the result of an API call in an APIError if it failed
+func checkRequest(op string, response *http.Response, err error) error {
+	if err == nil && (response == nil || response.StatusCode < 300) {
+		return nil
+	}
+
+	apiErr := &APIError{Op: op, Err: err}
+	if response != nil {
+		apiErr.StatusCode = response.StatusCode
+		if err == nil {
+			apiErr.Err = errors.New(response.Status)
+		}
+	}
+	return apiErr
+}
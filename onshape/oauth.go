@@ -0,0 +1,257 @@
+package onshape
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/oauth2"
+
+	"onshape-mcjf-exporter/internal/fileutil"
+)
+
+const (
+	DEFAULT_OAUTH_URL    = "https://oauth.onshape.com/oauth/"
+	DEFAULT_REDIRECT_URL = "http://localhost:8910/callback"
+	OAUTH_SCOPE          = "OAuth2Read"
+	TOKEN_FILE_NAME      = "oauth_token.json"
+)
+
+// OAuth application registered in the Onshape developer portal
+type OAuthConfig struct {
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// Loopback url registered as the redirect url of the application
+	RedirectURL string `json:"redirect_url"`
+	// Overrides DEFAULT_OAUTH_URL, e.g. for a staging server
+	OAuthURL string `json:"oauth_url"`
+	// Where the refresh token is kept, defaults to the user config directory
+	TokenFile string `json:"token_file"`
+}
+
+func (o OAuthConfig) Config() *oauth2.Config {
+	base := o.OAuthURL
+	if base == "" {
+		base = DEFAULT_OAUTH_URL
+	}
+	redirect := o.RedirectURL
+	if redirect == "" {
+		redirect = DEFAULT_REDIRECT_URL
+	}
+	return &oauth2.Config{
+		ClientID:     o.ClientId,
+		ClientSecret: o.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  base + "authorize",
+			TokenURL: base + "token",
+		},
+		RedirectURL: redirect,
+		Scopes:      []string{OAUTH_SCOPE},
+	}
+}
+
+func (o OAuthConfig) TokenStore() (*TokenStore, error) {
+	if o.TokenFile != "" {
+		return &TokenStore{Path: o.TokenFile}, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the token directory: %w", err)
+	}
+	return &TokenStore{Path: filepath.Join(dir, CACHE_DIR_NAME, TOKEN_FILE_NAME)}, nil
+}
+
+// Token saved between runs, readable by the current user only
+type TokenStore struct {
+	Path string
+}
+
+func (s *TokenStore) Load() (*oauth2.Token, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: not logged in, run the login command first", ErrAuth)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token: %w", err)
+	}
+
+	var token oauth2.Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse token %s: %w", s.Path, err)
+	}
+	return &token, nil
+}
+
+func (s *TokenStore) Save(token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteFileAtomic(s.Path, data); err != nil {
+		return fmt.Errorf("failed to save token: %w", err)
+	}
+	return nil
+}
+
+func (s *TokenStore) Delete() error {
+	if err := os.Remove(s.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Saves every refreshed token so the next run starts from it
+type storingTokenSource struct {
+	mu     sync.Mutex
+	source oauth2.TokenSource
+	store  *TokenStore
+	last   string
+}
+
+func (s *storingTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuth, err)
+	}
+	if token.AccessToken != s.last {
+		s.last = token.AccessToken
+		if err := s.store.Save(token); err != nil {
+			log.Printf("warning: %v", err)
+		}
+	}
+	return token, nil
+}
+
+// Token source for a user who logged in before, refreshing the stored token
+// when it expires
+func NewTokenSource(ctx context.Context, config OAuthConfig) (oauth2.TokenSource, error) {
+	store, err := config.TokenStore()
+	if err != nil {
+		return nil, err
+	}
+	token, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return &storingTokenSource{
+		source: config.Config().TokenSource(ctx, token),
+		store:  store,
+		last:   token.AccessToken,
+	}, nil
+}
+
+// Parses the redirect url of the login callback, which has to be a plain
+// http url on a loopback address with a port so that the listener is not
+// reachable from other machines
+func ParseRedirectURL(raw string) (*url.URL, error) {
+	redirect, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect url: %w", err)
+	}
+	host := redirect.Hostname()
+	ip := net.ParseIP(host)
+	if redirect.Scheme != "http" || (host != "localhost" && (ip == nil || !ip.IsLoopback())) || redirect.Port() == "" {
+		return nil, fmt.Errorf("redirect url %s must be an http url on a loopback address with a port", raw)
+	}
+	return redirect, nil
+}
+
+// Runs the authorization code flow: open is called with the consent page url,
+// and the code is received by a server listening on the loopback redirect
+// url. The resulting token is saved to the token store.
+func Login(ctx context.Context, config OAuthConfig, open func(url string) error) (*oauth2.Token, error) {
+	oauthConfig := config.Config()
+	redirect, err := ParseRedirectURL(oauthConfig.RedirectURL)
+	if err != nil {
+		return nil, err
+	}
+	callbackPath := redirect.Path
+	if callbackPath == "" {
+		callbackPath = "/"
+	}
+
+	stateBytes := make([]byte, 16)
+	if _, err := rand.Read(stateBytes); err != nil {
+		return nil, err
+	}
+	state := hex.EncodeToString(stateBytes)
+
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the login callback: %w", err)
+	}
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	// Only the first callback counts, a reload of the page is ignored
+	report := func(r result) {
+		select {
+		case results <- r:
+		default:
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(callbackPath, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case query.Get("state") != state:
+			http.Error(w, "Login failed: unexpected state.", http.StatusBadRequest)
+			return
+		case query.Get("error") != "":
+			http.Error(w, "Login failed: " + query.Get("error"), http.StatusBadRequest)
+			report(result{err: fmt.Errorf("%w: %s", ErrAuth, query.Get("error"))})
+			return
+		}
+		fmt.Fprintln(w, "Logged in to Onshape, you can close this window.")
+		report(result{code: query.Get("code")})
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Close()
+
+	if err := open(oauthConfig.AuthCodeURL(state)); err != nil {
+		return nil, err
+	}
+
+	var code string
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-results:
+		if r.err != nil {
+			return nil, r.err
+		}
+		code = r.code
+	}
+
+	token, err := oauthConfig.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuth, err)
+	}
+
+	store, err := config.TokenStore()
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Save(token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
@@ -0,0 +1,24 @@
+package onshape
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoginListensOnRedirectURLWithoutPath(t *testing.T) {
+	config := OAuthConfig{ClientId: "client", RedirectURL: "http://127.0.0.1:0", TokenFile: t.TempDir() + "/token.json"}
+	opened := errors.New("browser not available")
+	var consent string
+	_, err := Login(context.Background(), config, func(url string) error {
+		consent = url
+		return opened
+	})
+	if !errors.Is(err, opened) {
+		t.Fatalf("got %v, want the error of open", err)
+	}
+	if !strings.Contains(consent, "redirect_uri=http%3A%2F%2F127.0.0.1%3A0") {
+		t.Errorf("consent url %s does not carry the redirect url", consent)
+	}
+}
@@ -0,0 +1,95 @@
+package onshape
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"onshape-mcjf-exporter/onshapetest"
+)
+
+func TestSignRequestKnownVector(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://cad.onshape.com/api/v6/documents/d/abc/w/def/elements?elementId=XYZ", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	SignRequest(req, "ACCESS", "SECRET", date, "abcdefghijklmnopqrstuvwxy")
+
+	// HMAC-SHA256 with key SECRET of the lowercased canonical string
+	// "get\nabcdefghijklmnopqrstuvwxy\ntue, 02 jan 2024 03:04:05 gmt\n
+	// application/json\n/api/v6/documents/d/abc/w/def/elements\nelementid=xyz\n"
+	want := map[string]string{
+		"Authorization": "On ACCESS:HmacSHA256:D/VGWFlQrceUmErZGhW2hD8umk0b7XNhpSO4jd9IfBA=",
+		"Date":          "Tue, 02 Jan 2024 03:04:05 GMT",
+		"On-Nonce":      "abcdefghijklmnopqrstuvwxy",
+		"Content-Type":  "application/json",
+	}
+	for name, value := range want {
+		if got := req.Header.Get(name); got != value {
+			t.Errorf("%s %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestRedirectedStlDownloadIsSigned(t *testing.T) {
+	s := onshapetest.NewServer()
+	t.Cleanup(s.Close)
+	s.AddMesh("d1", "m", "mv1", "ps1", "JHD", []byte("solid JHD"))
+
+	client := NewClient(context.Background(), ClientOptions{
+		Credentials: Credentials{AccessKey: "ACCESS", SecretKey: "SECRET", Auth: AUTH_HMAC},
+		ServerURL:   s.URL + "/",
+		CacheDir:    t.TempDir(),
+	})
+	stl, err := client.ExportStl(ElementPath{DocumentId: "d1", WVM: "m", WVMId: "mv1", ElementId: "ps1"}, "JHD", "meter", "binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stl) != "solid JHD" {
+		t.Errorf("stl %q", stl)
+	}
+
+	for _, prefix := range []string{"/parts/d/d1/m/mv1/e/ps1/partid/JHD/stl", "/stl-download/"} {
+		header := s.LastHeader(prefix)
+		if header == nil {
+			t.Fatalf("no request for %s", prefix)
+		}
+		authorization := header.Get("Authorization")
+		if !strings.HasPrefix(authorization, "On ACCESS:HmacSHA256:") {
+			t.Errorf("%s authorized with %q, want an HMAC signature", prefix, authorization)
+		}
+		if len(header.Get("On-Nonce")) != NONCE_LENGTH {
+			t.Errorf("%s has nonce %q", prefix, header.Get("On-Nonce"))
+		}
+		date, err := http.ParseTime(header.Get("Date"))
+		if err != nil {
+			t.Errorf("%s has date %q: %v", prefix, header.Get("Date"), err)
+		}
+		if time.Since(date) > time.Minute {
+			t.Errorf("%s dated %v", prefix, date)
+		}
+	}
+
+	// The signature covers the path and query of the download itself
+	downloads := 0
+	for _, path := range s.Requests() {
+		if !strings.HasPrefix(path, "/stl-download/") {
+			continue
+		}
+		downloads++
+		header := s.LastHeader(path)
+		date, _ := http.ParseTime(header.Get("Date"))
+		expected, _ := http.NewRequest("GET", s.URL+path, nil)
+		expected.Header.Set("Content-Type", header.Get("Content-Type"))
+		SignRequest(expected, "ACCESS", "SECRET", date, header.Get("On-Nonce"))
+		if got, want := header.Get("Authorization"), expected.Header.Get("Authorization"); got != want {
+			t.Errorf("download signed %q, want %q", got, want)
+		}
+	}
+	if downloads != 1 {
+		t.Errorf("%d downloads, want 1", downloads)
+	}
+}
@@ -0,0 +1,133 @@
+package onshape
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"onshape-mcjf-exporter/internal/fileutil"
+)
+
+type FixtureMode int
+
+const (
+	FixturePassthrough FixtureMode = iota
+	// Forward every request and save the response as a fixture
+	FixtureRecord
+	// Serve responses from saved fixtures only
+	FixtureReplay
+)
+
+// Saved HTTP response. Only the headers the exporter reads are kept so that
+// cookies and other session data never end up in fixture files.
+type Fixture struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+var fixtureHeaders = []string{"Content-Type", "Location"}
+
+type FixtureTransport struct {
+	Dir  string
+	Mode FixtureMode
+	Next http.RoundTripper
+}
+
+func NewFixtureTransport(dir string, mode FixtureMode, next http.RoundTripper) *FixtureTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &FixtureTransport{Dir: dir, Mode: mode, Next: next}
+}
+
+// Requests are matched on method, path and query, never on host or headers,
+// so fixtures replay against any server and contain no credentials.
+func FixtureKey(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + req.URL.Path + "?" + req.URL.Query().Encode()))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *FixtureTransport) path(req *http.Request) string {
+	return filepath.Join(t.Dir, FixtureKey(req) + ".json")
+}
+
+func (t *FixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch t.Mode {
+	case FixtureRecord:
+		return t.record(req)
+	case FixtureReplay:
+		return t.replay(req)
+	default:
+		return t.Next.RoundTrip(req)
+	}
+}
+
+func (t *FixtureTransport) record(req *http.Request) (*http.Response, error) {
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fixture := Fixture{
+		Method:     req.Method,
+		URL:        req.URL.Path + "?" + req.URL.Query().Encode(),
+		StatusCode: resp.StatusCode,
+		Header:     http.Header{},
+		Body:       body,
+	}
+	for _, h := range fixtureHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			fixture.Header.Set(h, v)
+		}
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := fileutil.WriteFileAtomic(t.path(req), data); err != nil {
+		return nil, fmt.Errorf("failed to record fixture for %s %s: %w", req.Method, req.URL.Path, err)
+	}
+
+	return resp, nil
+}
+
+func (t *FixtureTransport) replay(req *http.Request) (*http.Response, error) {
+	data, err := os.ReadFile(t.path(req))
+	if err != nil {
+		return nil, fmt.Errorf("no fixture recorded for %s %s: %w", req.Method, req.URL.Path, err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("malformed fixture for %s %s: %w", req.Method, req.URL.Path, err)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.StatusCode, http.StatusText(fixture.StatusCode)),
+		StatusCode:    fixture.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        fixture.Header,
+		Body:          io.NopCloser(bytes.NewReader(fixture.Body)),
+		ContentLength: int64(len(fixture.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,318 @@
+// Package onshapetest provides a fake Onshape server implementing the subset
+// of the REST API used by the exporter, so the whole pipeline can run without
+// network access or credentials.
+package onshapetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/onshape-public/go-client/onshape"
+)
+
+const API_PREFIX = "/api/v6"
+
+type elementKey struct {
+	did           string
+	wvm           string
+	wvmid         string
+	eid           string
+	configuration string
+}
+
+type meshKey struct {
+	elementKey
+	partId string
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	documents  map[string]string
+	elements   map[elementKey]onshape.BTDocumentElementInfo
+	assemblies map[elementKey]onshape.BTAssemblyDefinitionInfo
+	parts      map[elementKey][]onshape.BTPartMetadataInfo
+	meshes     map[meshKey][]byte
+	masses     map[meshKey]onshape.BTMassPropertiesInfo
+	// Current microversion of each workspace and version
+	microversions  map[elementKey]string
+	versions       map[string][]onshape.BTVersionInfo
+	configurations map[elementKey]onshape.BTConfigurationResponse2019
+	requests       []string
+	// Headers of each request, in the order of requests
+	headers []http.Header
+}
+
+func NewServer() *Server {
+	s := &Server{
+		documents:      make(map[string]string),
+		elements:       make(map[elementKey]onshape.BTDocumentElementInfo),
+		assemblies:     make(map[elementKey]onshape.BTAssemblyDefinitionInfo),
+		parts:          make(map[elementKey][]onshape.BTPartMetadataInfo),
+		meshes:         make(map[meshKey][]byte),
+		masses:         make(map[meshKey]onshape.BTMassPropertiesInfo),
+		microversions:  make(map[elementKey]string),
+		versions:       make(map[string][]onshape.BTVersionInfo),
+		configurations: make(map[elementKey]onshape.BTConfigurationResponse2019),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Browser style URL of an element, as pasted into the exporter config
+func (s *Server) ElementURL(did string, wvm string, wvmid string, eid string) string {
+	return s.URL + "/documents/" + did + "/" + wvm + "/" + wvmid + "/e/" + eid
+}
+
+// Base path to configure the API client with
+func (s *Server) APIURL() string {
+	return s.URL + API_PREFIX
+}
+
+func (s *Server) AddDocument(did string, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.documents[did] = name
+}
+
+func (s *Server) AddElement(did string, wvm string, wvmid string, eid string, name string, elementType onshape.GBTElementType) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.elements[elementKey{did: did, wvm: wvm, wvmid: wvmid, eid: eid}] = onshape.BTDocumentElementInfo{
+		Id:          &eid,
+		Name:        &name,
+		ElementType: &elementType,
+	}
+}
+
+func (s *Server) AddAssembly(did string, wvm string, wvmid string, eid string, def onshape.BTAssemblyDefinitionInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.assemblies[elementKey{did: did, wvm: wvm, wvmid: wvmid, eid: eid}] = def
+}
+
+// Assembly returned for one encoded configuration, e.g. "List_side=Left"
+func (s *Server) AddConfiguredAssembly(did string, wvm string, wvmid string, eid string, configuration string, def onshape.BTAssemblyDefinitionInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.assemblies[elementKey{did: did, wvm: wvm, wvmid: wvmid, eid: eid, configuration: configuration}] = def
+}
+
+func (s *Server) AddParts(did string, wvm string, wvmid string, eid string, parts []onshape.BTPartMetadataInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := elementKey{did: did, wvm: wvm, wvmid: wvmid, eid: eid}
+	s.parts[key] = append(s.parts[key], parts...)
+}
+
+func (s *Server) AddConfiguredParts(did string, wvm string, wvmid string, eid string, configuration string, parts []onshape.BTPartMetadataInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := elementKey{did: did, wvm: wvm, wvmid: wvmid, eid: eid, configuration: configuration}
+	s.parts[key] = append(s.parts[key], parts...)
+}
+
+func (s *Server) AddMesh(did string, wvm string, wvmid string, eid string, partId string, stl []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.meshes[meshKey{elementKey{did: did, wvm: wvm, wvmid: wvmid, eid: eid}, partId}] = stl
+}
+
+// Mass in kilograms and centroid in meters of a part
+func (s *Server) AddMassProperties(did string, wvm string, wvmid string, eid string, partId string, mass float64, centroid [3]float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hasMass := true
+	s.masses[meshKey{elementKey{did: did, wvm: wvm, wvmid: wvmid, eid: eid}, partId}] = onshape.BTMassPropertiesInfo{
+		HasMass_: &hasMass,
+		Mass:     []float64{mass, mass, mass},
+		Centroid: centroid[:],
+	}
+}
+
+func (s *Server) SetMicroversion(did string, wv string, wvid string, microversion string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.microversions[elementKey{did: did, wvm: wv, wvmid: wvid}] = microversion
+}
+
+func (s *Server) AddVersion(did string, vid string, name string, microversion string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[did] = append(s.versions[did], onshape.BTVersionInfo{
+		Id:           &vid,
+		Name:         &name,
+		DocumentId:   &did,
+		Microversion: &microversion,
+	})
+	s.microversions[elementKey{did: did, wvm: "v", wvmid: vid}] = microversion
+}
+
+// Makes an element configurable with a list parameter
+func (s *Server) AddConfigurationList(did string, wvm string, wvmid string, eid string, parameterId string, parameterName string, optionIds []string, optionNames []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	btType := "BTMConfigurationParameterEnum-105"
+	list := onshape.BTMConfigurationParameterEnum105{
+		BtType:        &btType,
+		ParameterId:   &parameterId,
+		ParameterName: &parameterName,
+		OptionIds:     optionIds,
+	}
+	for i := range optionIds {
+		list.Options = append(list.Options, onshape.BTMEnumOption592{Option: &optionIds[i], OptionName: &optionNames[i]})
+	}
+
+	key := elementKey{did: did, wvm: wvm, wvmid: wvmid, eid: eid}
+	configuration := s.configurations[key]
+	configuration.ConfigurationParameters = append(configuration.ConfigurationParameters, *list.AsBTMConfigurationParameter819())
+	s.configurations[key] = configuration
+}
+
+// Paths of every request served so far, without the API prefix
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Headers of the last request served whose path starts with prefix, nil if
+// there was none
+func (s *Server) LastHeader(prefix string) http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if strings.HasPrefix(s.requests[i], prefix) {
+			return s.headers[i].Clone()
+		}
+	}
+	return nil
+}
+
+// Number of requests served whose path starts with prefix
+func (s *Server) RequestCount(prefix string) int {
+	count := 0
+	for _, r := range s.Requests() {
+		if strings.HasPrefix(r, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, API_PREFIX)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, path)
+	s.headers = append(s.headers, r.Header.Clone())
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	// /assemblies/d/{did}/{wvm}/{wvmid}/e/{eid}
+	case len(segments) == 7 && segments[0] == "assemblies" && segments[1] == "d" && segments[5] == "e":
+		def, ok := s.assemblies[s.configured(r, elementKey{did: segments[2], wvm: segments[3], wvmid: segments[4], eid: segments[6]}, s.hasAssembly)]
+		s.writeJSON(w, def, ok)
+
+	// /parts/d/{did}/{wvm}/{wvmid}/e/{eid}
+	case len(segments) == 7 && segments[0] == "parts" && segments[1] == "d" && segments[5] == "e":
+		parts, ok := s.parts[s.configured(r, elementKey{did: segments[2], wvm: segments[3], wvmid: segments[4], eid: segments[6]}, s.hasParts)]
+		s.writeJSON(w, parts, ok)
+
+	// /parts/d/{did}/{wvm}/{wvmid}/e/{eid}/partid/{partid}/stl
+	case len(segments) == 10 && segments[0] == "parts" && segments[7] == "partid" && segments[9] == "stl":
+		key := meshKey{elementKey{did: segments[2], wvm: segments[3], wvmid: segments[4], eid: segments[6]}, segments[8]}
+		if _, ok := s.meshes[key]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		// Onshape serves the actual file from a different location
+		w.Header().Set("Location", s.URL + "/stl-download/" + strings.Join(segments[2:9], "/"))
+		w.WriteHeader(http.StatusTemporaryRedirect)
+
+	// /parts/d/{did}/{wvm}/{wvmid}/e/{eid}/partid/{partid}/massproperties
+	case len(segments) == 10 && segments[0] == "parts" && segments[7] == "partid" && segments[9] == "massproperties":
+		mass, ok := s.masses[meshKey{elementKey{did: segments[2], wvm: segments[3], wvmid: segments[4], eid: segments[6]}, segments[8]}]
+		bodies := map[string]onshape.BTMassPropertiesInfo{segments[8]: mass}
+		s.writeJSON(w, onshape.BTMassPropertiesBulkInfo{Bodies: &bodies}, ok)
+
+	// /stl-download/{did}/{wvm}/{wvmid}/e/{eid}/partid/{partid}
+	case len(segments) == 8 && segments[0] == "stl-download":
+		stl, ok := s.meshes[meshKey{elementKey{did: segments[1], wvm: segments[2], wvmid: segments[3], eid: segments[5]}, segments[7]}]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(stl)
+
+	// /elements/d/{did}/{wvm}/{wvmid}/e/{eid}/configuration
+	case len(segments) == 8 && segments[0] == "elements" && segments[1] == "d" && segments[7] == "configuration":
+		configuration, ok := s.configurations[elementKey{did: segments[2], wvm: segments[3], wvmid: segments[4], eid: segments[6]}]
+		if !ok {
+			// Elements without configuration inputs have an empty configuration
+			_, ok = s.elements[elementKey{did: segments[2], wvm: segments[3], wvmid: segments[4], eid: segments[6]}]
+		}
+		s.writeJSON(w, configuration, ok)
+
+	// /documents/d/{did}/{wvm}/{wvmid}/elements
+	case len(segments) == 6 && segments[0] == "documents" && segments[1] == "d" && segments[5] == "elements":
+		key := elementKey{did: segments[2], wvm: segments[3], wvmid: segments[4], eid: r.URL.Query().Get("elementId")}
+		element, ok := s.elements[key]
+		s.writeJSON(w, []onshape.BTDocumentElementInfo{element}, ok)
+
+	// /documents/d/{did}/{wv}/{wvid}/currentmicroversion
+	case len(segments) == 6 && segments[0] == "documents" && segments[1] == "d" && segments[5] == "currentmicroversion":
+		microversion, ok := s.microversions[elementKey{did: segments[2], wvm: segments[3], wvmid: segments[4]}]
+		s.writeJSON(w, map[string]string{"microversion": microversion}, ok)
+
+	// /documents/d/{did}/versions
+	case len(segments) == 4 && segments[0] == "documents" && segments[1] == "d" && segments[3] == "versions":
+		_, ok := s.documents[segments[2]]
+		s.writeJSON(w, append([]onshape.BTVersionInfo{}, s.versions[segments[2]]...), ok)
+
+	// /documents/{did}
+	case len(segments) == 2 && segments[0] == "documents":
+		name, ok := s.documents[segments[1]]
+		s.writeJSON(w, map[string]string{"id": segments[1], "name": name}, ok)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Key for the configuration in the request query, or the unconfigured key
+// when nothing was added for that configuration
+func (s *Server) configured(r *http.Request, key elementKey, exists func(elementKey) bool) elementKey {
+	configured := key
+	configured.configuration = r.URL.Query().Get("configuration")
+	if exists(configured) {
+		return configured
+	}
+	return key
+}
+
+func (s *Server) hasAssembly(key elementKey) bool {
+	_, ok := s.assemblies[key]
+	return ok
+}
+
+func (s *Server) hasParts(key elementKey) bool {
+	_, ok := s.parts[key]
+	return ok
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, v any, ok bool) {
+	if !ok {
+		http.Error(w, `{"message": "not found"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,185 @@
+package onshapetest
+
+import (
+	"fmt"
+
+	"github.com/onshape-public/go-client/onshape"
+)
+
+// Microversion, part studio and sub-assembly of the documents made by
+// SyntheticAssembly and RepeatedAssembly
+const (
+	SYNTHETIC_MICROVERSION = "synthetic"
+	SYNTHETIC_PART_STUDIO  = "partstudio"
+	SYNTHETIC_LEG          = "leg"
+)
+
+// Assembly definition depth levels deep, where the root and every
+// sub-assembly hold width instances of the sub-assembly one level down and
+// parts part instances. All parts come from one part studio in document did.
+// Large counts stand in for production assemblies with thousands of
+// occurrences.
+func SyntheticAssembly(did string, depth int, width int, parts int) onshape.BTAssemblyDefinitionInfo {
+	mv := SYNTHETIC_MICROVERSION
+	partStudio := SYNTHETIC_PART_STUDIO
+	partType := onshape.BTAssemblyInstanceTypePart
+	assemblyType := onshape.BTAssemblyInstanceTypeAssembly
+	standard := false
+
+	def := onshape.BTAssemblyDefinitionInfo{RootAssembly: &onshape.BTRootAssemblyInfo{}}
+	partIds := make([]string, parts)
+	for i := range partIds {
+		partIds[i] = syntheticPartId(i)
+		def.Parts = append(def.Parts, onshape.BTAssemblyPartInfo{DocumentId: &did, DocumentMicroversion: &mv, ElementId: &partStudio, PartId: &partIds[i], IsStandardContent: &standard})
+	}
+
+	// The same instances at every level, the sub-assembly instances
+	// referencing the element of the next level
+	instances := func(level int) []onshape.BTAssemblyInstanceInfo {
+		list := make([]onshape.BTAssemblyInstanceInfo, 0, width+parts)
+		if level < depth {
+			element := syntheticSubAssembly(level + 1)
+			for j := 0; j < width; j++ {
+				id := fmt.Sprintf("asm%d", j)
+				name := fmt.Sprintf("Module %d <%d>", level+1, j+1)
+				list = append(list, onshape.BTAssemblyInstanceInfo{Id: &id, Name: &name, Type: &assemblyType, DocumentId: &did, DocumentMicroversion: &mv, ElementId: &element})
+			}
+		}
+		for i := range partIds {
+			id := fmt.Sprintf("part%d", i)
+			name := fmt.Sprintf("Part %d <1>", i)
+			list = append(list, onshape.BTAssemblyInstanceInfo{Id: &id, Name: &name, Type: &partType, DocumentId: &did, DocumentMicroversion: &mv, ElementId: &partStudio, PartId: &partIds[i]})
+		}
+		return list
+	}
+	def.RootAssembly.Instances = instances(0)
+	for level := 1; level <= depth; level++ {
+		element := syntheticSubAssembly(level)
+		def.SubAssemblies = append(def.SubAssemblies, onshape.BTSubAssemblyInfo{DocumentId: &did, DocumentMicroversion: &mv, ElementId: &element, Instances: instances(level)})
+	}
+
+	// Every path through the tree, parents before their children
+	var occurrences func(parent []string, level int)
+	occurrences = func(parent []string, level int) {
+		for k, instance := range instances(level) {
+			path := append(append([]string{}, parent...), *instance.Id)
+			def.RootAssembly.Occurrences = append(def.RootAssembly.Occurrences, onshape.BTAssemblyOccurrenceInfo{Path: path, Transform: translation(float64(k), float64(level), 0)})
+			if *instance.Type == assemblyType {
+				occurrences(path, level+1)
+			}
+		}
+	}
+	occurrences(nil, 0)
+	return def
+}
+
+// Serves SyntheticAssembly at the element along with the part metadata,
+// meshes and element names it references
+func (s *Server) AddSyntheticAssembly(did string, wvm string, wvmid string, eid string, depth int, width int, parts int) {
+	s.AddDocument(did, "Synthetic")
+	s.AddElement(did, wvm, wvmid, eid, "Synthetic", onshape.GBTElementTypeAssembly)
+	for level := 1; level <= depth; level++ {
+		s.AddElement(did, "m", SYNTHETIC_MICROVERSION, syntheticSubAssembly(level), fmt.Sprintf("Module %d", level), onshape.GBTElementTypeAssembly)
+	}
+	s.AddAssembly(did, wvm, wvmid, eid, SyntheticAssembly(did, depth, width, parts))
+	s.addSyntheticParts(did, parts)
+}
+
+// Assembly definition with legs instances "Leg <i>" of one sub-assembly, each
+// holding feet instances "Foot <k>" of a part, and plates instances
+// "Plate <k>" of the same part at the top level. Leg i is turned 90° about z
+// at (i, 0, 0), foot k sits at (0, k, 0) in its leg and plate k at (0, 0, k).
+func RepeatedAssembly(did string, legs int, feet int, plates int) onshape.BTAssemblyDefinitionInfo {
+	mv := SYNTHETIC_MICROVERSION
+	partStudio := SYNTHETIC_PART_STUDIO
+	partId := syntheticPartId(0)
+	leg := SYNTHETIC_LEG
+	partType := onshape.BTAssemblyInstanceTypePart
+	assemblyType := onshape.BTAssemblyInstanceTypeAssembly
+	standard := false
+	part := func(id string, name string) onshape.BTAssemblyInstanceInfo {
+		return onshape.BTAssemblyInstanceInfo{Id: &id, Name: &name, Type: &partType, DocumentId: &did, DocumentMicroversion: &mv, ElementId: &partStudio, PartId: &partId}
+	}
+
+	def := onshape.BTAssemblyDefinitionInfo{
+		Parts:        []onshape.BTAssemblyPartInfo{{DocumentId: &did, DocumentMicroversion: &mv, ElementId: &partStudio, PartId: &partId, IsStandardContent: &standard}},
+		RootAssembly: &onshape.BTRootAssemblyInfo{},
+	}
+	feetInstances := make([]onshape.BTAssemblyInstanceInfo, 0, feet)
+	for k := 1; k <= feet; k++ {
+		feetInstances = append(feetInstances, part(fmt.Sprintf("foot%d", k), fmt.Sprintf("Foot <%d>", k)))
+	}
+	if legs > 0 {
+		def.SubAssemblies = []onshape.BTSubAssemblyInfo{{DocumentId: &did, DocumentMicroversion: &mv, ElementId: &leg, Instances: feetInstances}}
+	}
+
+	root := def.RootAssembly
+	for i := 1; i <= legs; i++ {
+		id := fmt.Sprintf("leg%d", i)
+		name := fmt.Sprintf("Leg <%d>", i)
+		root.Instances = append(root.Instances, onshape.BTAssemblyInstanceInfo{Id: &id, Name: &name, Type: &assemblyType, DocumentId: &did, DocumentMicroversion: &mv, ElementId: &leg})
+		// Onshape gives every occurrence in the frame of the root
+		root.Occurrences = append(root.Occurrences, onshape.BTAssemblyOccurrenceInfo{Path: []string{id}, Transform: turnedZ(float64(i), 0, 0)})
+		for k, foot := range feetInstances {
+			root.Occurrences = append(root.Occurrences, onshape.BTAssemblyOccurrenceInfo{Path: []string{id, foot.GetId()}, Transform: turnedZ(float64(i-k-1), 0, 0)})
+		}
+	}
+	for k := 1; k <= plates; k++ {
+		instance := part(fmt.Sprintf("plate%d", k), fmt.Sprintf("Plate <%d>", k))
+		root.Instances = append(root.Instances, instance)
+		root.Occurrences = append(root.Occurrences, onshape.BTAssemblyOccurrenceInfo{Path: []string{instance.GetId()}, Transform: translation(0, 0, float64(k))})
+	}
+	return def
+}
+
+// Serves RepeatedAssembly at the element along with the part metadata, mesh
+// and element names it references
+func (s *Server) AddRepeatedAssembly(did string, wvm string, wvmid string, eid string, legs int, feet int, plates int) {
+	s.AddDocument(did, "Repeated")
+	s.AddElement(did, wvm, wvmid, eid, "Repeated", onshape.GBTElementTypeAssembly)
+	s.AddElement(did, "m", SYNTHETIC_MICROVERSION, SYNTHETIC_LEG, "Leg", onshape.GBTElementTypeAssembly)
+	s.AddAssembly(did, wvm, wvmid, eid, RepeatedAssembly(did, legs, feet, plates))
+	s.addSyntheticParts(did, 1)
+}
+
+// Metadata and mesh of the first parts of the synthetic part studio
+func (s *Server) addSyntheticParts(did string, parts int) {
+	var gray, opaque int32 = 128, 255
+	appearance := onshape.BTPartAppearanceInfo{Color: &onshape.BTColorInfo{Red: &gray, Green: &gray, Blue: &gray}, Opacity: &opaque}
+	metadata := make([]onshape.BTPartMetadataInfo, 0, parts)
+	for i := 0; i < parts; i++ {
+		partId := syntheticPartId(i)
+		name := fmt.Sprintf("Part %d", i)
+		metadata = append(metadata, onshape.BTPartMetadataInfo{PartId: &partId, Name: &name, Appearance: &appearance})
+		s.AddMesh(did, "m", SYNTHETIC_MICROVERSION, SYNTHETIC_PART_STUDIO, partId, []byte("solid "+partId))
+	}
+	s.AddParts(did, "m", SYNTHETIC_MICROVERSION, SYNTHETIC_PART_STUDIO, metadata)
+}
+
+func syntheticSubAssembly(level int) string {
+	return fmt.Sprintf("subassembly%d", level)
+}
+
+func syntheticPartId(i int) string {
+	return fmt.Sprintf("P%d", i)
+}
+
+// Row-major Onshape transform of a pure translation
+func translation(x float64, y float64, z float64) []float64 {
+	return []float64{
+		1, 0, 0, x,
+		0, 1, 0, y,
+		0, 0, 1, z,
+		0, 0, 0, 1,
+	}
+}
+
+// Row-major Onshape transform turning 90° about z, then translating
+func turnedZ(x float64, y float64, z float64) []float64 {
+	return []float64{
+		0, -1, 0, x,
+		1, 0, 0, y,
+		0, 0, 1, z,
+		0, 0, 0, 1,
+	}
+}
@@ -0,0 +1,81 @@
+package exporter
+
+import (
+	"fmt"
+
+	"onshape-mcjf-exporter/mjcf"
+	"onshape-mcjf-exporter/onshape"
+)
+
+// Pin value resolving a workspace or version to its current microversion
+const PIN_CURRENT = "current"
+
+// Set at build time with -ldflags "-X onshape-mcjf-exporter.Version=..."
+var Version = "devel"
+
+// Resolves element to an immutable reference and describes where the model
+// comes from, including the current microversion of a workspace that is not
+// pinned. pin is empty to export element as is, PIN_CURRENT for the
+// current microversion of a workspace or version, or the name or id of a
+// document version.
+func PinElement(client *onshape.Client, element *onshape.Element, pin string) (*onshape.Element, *mjcf.Provenance, error) {
+	pinned := *element
+	provenance := &mjcf.Provenance{
+		Source:        element.URL(),
+		DocumentId:    element.DocumentId,
+		ElementId:     element.ElementId,
+		Configuration: element.Configuration,
+		ToolVersion:   Version,
+	}
+	switch element.WVM {
+	case "w":
+		provenance.WorkspaceId = element.WVMId
+	case "v":
+		provenance.VersionId = element.WVMId
+	case "m":
+		provenance.Microversion = element.WVMId
+	}
+
+	switch {
+	case pin == "" && element.WVM == "w":
+		// Exported from the workspace as is, but the model still records the
+		// CAD state it was read at
+		microversion, err := client.GetCurrentMicroversion(element.ElementPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read the microversion of %s: %w", element.URL(), err)
+		}
+		provenance.Microversion = microversion
+		return &pinned, provenance, nil
+
+	case pin == "":
+		return &pinned, provenance, nil
+
+	case pin == PIN_CURRENT && element.WVM == "m":
+		return &pinned, provenance, nil
+
+	case pin == PIN_CURRENT:
+		microversion, err := client.GetCurrentMicroversion(element.ElementPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to pin %s: %w", element.URL(), err)
+		}
+		pinned.WVM = "m"
+		pinned.WVMId = microversion
+		provenance.Microversion = microversion
+
+	default:
+		version, err := client.FindVersion(element.DocumentId, pin)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to pin %s: %w", element.URL(), err)
+		}
+		pinned.WVM = "v"
+		pinned.WVMId = version.GetId()
+		provenance.VersionId = version.GetId()
+		provenance.VersionName = version.GetName()
+		provenance.Microversion = version.GetMicroversion()
+	}
+
+	if err := pinned.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("failed to pin %s: %w", element.URL(), err)
+	}
+	return &pinned, provenance, nil
+}